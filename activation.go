@@ -0,0 +1,136 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Activation is a neural network activation function
+type Activation func(x float32) float32
+
+// ComplexActivation is a complex neural network activation function
+type ComplexActivation func(x complex64) complex64
+
+// Sigmoid is the logistic sigmoid activation function
+// Large inputs overflow the exponential to infinity, so they are clamped to 1 instead of evaluating to NaN
+func Sigmoid(x float32) float32 {
+	e := float32(math.Exp(float64(x)))
+	if math.IsInf(float64(e), 1) {
+		return 1
+	}
+	return e / (e + 1)
+}
+
+// Tanh is the hyperbolic tangent activation function
+func Tanh(x float32) float32 {
+	return float32(math.Tanh(float64(x)))
+}
+
+// ReLU is the rectified linear activation function
+func ReLU(x float32) float32 {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+// ComplexSigmoid is the complex logistic sigmoid activation function
+// Inputs that overflow the exponential to infinity are clamped to 1 instead of evaluating to NaN
+func ComplexSigmoid(x complex64) complex64 {
+	e := complex64(cmplx.Exp(complex128(x)))
+	if cmplx.IsInf(complex128(e)) {
+		return 1
+	}
+	return e / (e + 1)
+}
+
+// ComplexTanh is the complex hyperbolic tangent activation function
+func ComplexTanh(x complex64) complex64 {
+	return complex64(cmplx.Tanh(complex128(x)))
+}
+
+// ComplexReLU applies the rectified linear activation function to the real and imaginary parts separately
+func ComplexReLU(x complex64) complex64 {
+	return complex(ReLU(real(x)), ReLU(imag(x)))
+}
+
+// Activations are the activation functions by name
+var Activations = map[string]Activation{
+	"sigmoid": Sigmoid,
+	"tanh":    Tanh,
+	"relu":    ReLU,
+}
+
+// ComplexActivations are the complex activation functions by name
+var ComplexActivations = map[string]ComplexActivation{
+	"sigmoid": ComplexSigmoid,
+	"tanh":    ComplexTanh,
+	"relu":    ComplexReLU,
+}
+
+// LookupActivation returns the activation function with the given name, the empty name is the sigmoid
+func LookupActivation(name string) Activation {
+	if name == "" {
+		return Sigmoid
+	}
+	activation, ok := Activations[name]
+	if !ok {
+		panic(fmt.Errorf("unknown activation: %s", name))
+	}
+	return activation
+}
+
+// LookupComplexActivation returns the complex activation function with the given name, the empty name is the sigmoid
+func LookupComplexActivation(name string) ComplexActivation {
+	if name == "" {
+		return ComplexSigmoid
+	}
+	activation, ok := ComplexActivations[name]
+	if !ok {
+		panic(fmt.Errorf("unknown activation: %s", name))
+	}
+	return activation
+}
+
+// Argmax returns the index of the largest value
+// Ties go to the lowest index and NaN values never win unless every value is NaN, in which case the index is 0
+func Argmax(values []float32) int {
+	index := 0
+	for j, value := range values {
+		if value > values[index] || (math.IsNaN(float64(values[index])) && !math.IsNaN(float64(value))) {
+			index = j
+		}
+	}
+	return index
+}
+
+// ArgmaxComplex returns the index of the value with the largest magnitude, breaking ties like Argmax
+func ArgmaxComplex(values []complex64) int {
+	magnitudes := make([]float32, len(values))
+	for j, value := range values {
+		magnitudes[j] = float32(cmplx.Abs(complex128(value)))
+	}
+	return Argmax(magnitudes)
+}
+
+// Softmax returns the exponential of each value divided by the sum of the exponentials
+func Softmax(values []float32) []float32 {
+	if len(values) == 0 {
+		return nil
+	}
+	max := values[Argmax(values)]
+	softmax, sum := make([]float32, len(values)), float32(0)
+	for j, value := range values {
+		softmax[j] = float32(math.Exp(float64(value - max)))
+		sum += softmax[j]
+	}
+	for j := range softmax {
+		softmax[j] /= sum
+	}
+	return softmax
+}
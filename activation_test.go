@@ -0,0 +1,101 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestActivations(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		x        float32
+		expected float32
+	}{
+		{"sigmoid", 0, .5},
+		{"sigmoid", 1000, 1},
+		{"sigmoid", -1000, 0},
+		{"tanh", 0, 0},
+		{"tanh", 1000, 1},
+		{"relu", -2, 0},
+		{"relu", 2, 2},
+		{"", 0, .5},
+	} {
+		if y := LookupActivation(c.name)(c.x); y != c.expected {
+			t.Errorf("%s(%f) is %f, expected %f", c.name, c.x, y, c.expected)
+		}
+	}
+	if y := ComplexSigmoid(1000); y != 1 {
+		t.Errorf("complex sigmoid of 1000 is %v, expected 1", y)
+	}
+	if y := ComplexSigmoid(0); y != .5 {
+		t.Errorf("complex sigmoid of 0 is %v, expected .5", y)
+	}
+	if y := ComplexReLU(complex(-1, 2)); y != complex(0, 2) {
+		t.Errorf("complex relu of -1+2i is %v, expected 2i", y)
+	}
+	if y := LookupComplexActivation("tanh")(0); y != 0 {
+		t.Errorf("complex tanh of 0 is %v, expected 0", y)
+	}
+	for name := range Activations {
+		if _, ok := ComplexActivations[name]; !ok {
+			t.Errorf("activation %s has no complex activation", name)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("unknown activation didn't panic")
+		}
+	}()
+	LookupActivation("step")
+}
+
+func TestArgmax(t *testing.T) {
+	nan := float32(math.NaN())
+	for _, c := range []struct {
+		values   []float32
+		expected int
+	}{
+		{[]float32{.1, .7, .2}, 1},
+		{[]float32{.5, .2, .5}, 0},
+		{[]float32{.2, .5, .5}, 1},
+		{[]float32{nan, .1, .3}, 2},
+		{[]float32{.4, nan, .3}, 0},
+		{[]float32{nan, nan}, 0},
+	} {
+		if index := Argmax(c.values); index != c.expected {
+			t.Errorf("argmax of %v is %d, expected %d", c.values, index, c.expected)
+		}
+	}
+	if index := ArgmaxComplex([]complex64{1, -2i, 2}); index != 1 {
+		t.Errorf("complex argmax is %d, expected the first of the largest magnitudes 1", index)
+	}
+}
+
+func TestSoftmax(t *testing.T) {
+	softmax := Softmax([]float32{1, 2, 3, 1000})
+	sum := float32(0)
+	for _, value := range softmax {
+		if math.IsNaN(float64(value)) {
+			t.Fatalf("softmax %v has NaN", softmax)
+		}
+		sum += value
+	}
+	if math.Abs(float64(sum-1)) > 1e-6 || Argmax(softmax) != 3 {
+		t.Fatalf("softmax %v, expected a distribution peaked at 3", softmax)
+	}
+	softmax = Softmax([]float32{0, float32(math.Log(3))})
+	if math.Abs(float64(softmax[0]-.25)) > 1e-6 || math.Abs(float64(softmax[1]-.75)) > 1e-6 {
+		t.Fatalf("softmax %v, expected .25 and .75", softmax)
+	}
+	if Softmax(nil) != nil {
+		t.Fatal("softmax of nothing is not nil")
+	}
+	if !cmplx.IsNaN(complex128(ComplexTanh(complex(float32(math.NaN()), 0)))) {
+		t.Fatal("complex tanh of NaN is not NaN")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// Family is a family of models
+type Family struct {
+	Name  string
+	Model func(seed int) (interface{}, float64)
+}
+
+// Families are the model families compared in auto mode
+var Families = []Family{
+	{
+		Name: "real",
+		Model: func(seed int) (interface{}, float64) {
+			return RealNetworkModelBest(seed)
+		},
+	},
+	{
+		Name: "shared",
+		Model: func(seed int) (interface{}, float64) {
+			return SharedNetworkModelBest(seed)
+		},
+	},
+	{
+		Name: "complex",
+		Model: func(seed int) (interface{}, float64) {
+			return ComplexNetworkModelBest(seed)
+		},
+	},
+	{
+		Name: "random",
+		Model: func(seed int) (interface{}, float64) {
+			return RandomNetworkModelBest(seed)
+		},
+	},
+}
+
+// Auto runs each family over seeds seeds and returns the family with the best quality and its champion
+func Auto(families []Family, seeds int) (family string, network interface{}, quality float64) {
+	quality = 2
+	for _, f := range families {
+		for seed := 0; seed < seeds; seed++ {
+			n, q := f.Model(seed * NumGenomes)
+			if q < quality {
+				family, network, quality = f.Name, n, q
+			}
+		}
+	}
+	return family, network, quality
+}
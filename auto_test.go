@@ -0,0 +1,28 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestAuto(t *testing.T) {
+	var seeds []int
+	family := func(name string, quality float64) Family {
+		return Family{
+			Name: name,
+			Model: func(seed int) (interface{}, float64) {
+				seeds = append(seeds, seed)
+				return seed, quality + float64(seed)/NumGenomes/100
+			},
+		}
+	}
+	name, network, quality := Auto([]Family{family("a", .5), family("b", .2), family("c", .2)}, 3)
+	// the first seed of the first family with the lowest quality wins
+	if name != "b" || network != 0 || quality != .2 {
+		t.Fatalf("auto picked seed %v of %s with a quality of %f, expected seed 0 of b with .2", network, name, quality)
+	}
+	if len(seeds) != 9 || seeds[1] != NumGenomes || seeds[2] != 2*NumGenomes {
+		t.Fatalf("auto trained the seeds %v, expected 0, %d, and %d for each family", seeds, NumGenomes, 2*NumGenomes)
+	}
+}
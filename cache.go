@@ -0,0 +1,89 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "math"
+
+// CachedLayer is the random weights of a real network layer generated once from its seed
+type CachedLayer struct {
+	Indexes []int
+	Weights []float32
+	Factor  float32
+}
+
+// CachedNetwork is a real network with the random weights of its layers generated once,
+// so that inference over many samples does not regenerate them for every sample
+type CachedNetwork struct {
+	Network RealNetwork
+	Layers  []CachedLayer
+}
+
+// NewCachedNetwork generates the random weights of each layer of a network
+func NewCachedNetwork(n RealNetwork) CachedNetwork {
+	cached := CachedNetwork{
+		Network: n,
+		Layers:  make([]CachedLayer, len(n)),
+	}
+	for i, layer := range n {
+		if layer.Dense {
+			continue
+		}
+		rnd := NewSource(layer.Rand)
+		columns := n.Outputs()
+		if i < len(n)-1 {
+			columns = n[i+1].Columns
+		}
+		rows := len(layer.Biases)
+		l := CachedLayer{
+			Indexes: make([]int, rows),
+			Weights: make([]float32, rows*layer.Columns),
+			Factor:  float32(math.Sqrt(2 / float64(columns))),
+		}
+		for j := 0; j < rows; j++ {
+			l.Indexes[j] = int(rnd.Uint32() % uint32(layer.Columns))
+			MaterializeRow(rnd, l.Weights[j*layer.Columns:(j+1)*layer.Columns], l.Indexes[j])
+		}
+		cached.Layers[i] = l
+	}
+	return cached
+}
+
+// Inference performs inference on a neural network with cached random weights
+// The outputs are identical to the inference of the uncached network
+func (c CachedNetwork) Inference(inputs, outputs []float32) {
+	n := c.Network
+	last := len(n) - 1
+	for i, layer := range n {
+		activation, cached := LookupActivation(layer.Activation), c.Layers[i]
+		columns := len(outputs)
+		if i < len(n)-1 {
+			columns = n[i+1].Columns
+		}
+		values := make([]float32, columns)
+		for j, bias := range layer.Biases {
+			sum := bias
+			if layer.Dense {
+				for k, input := range inputs {
+					sum += input * layer.Weights[j*layer.Columns+k]
+				}
+			} else {
+				index, weights := cached.Indexes[j], cached.Weights[j*layer.Columns:(j+1)*layer.Columns]
+				for k, input := range inputs {
+					if k == index {
+						sum += input * layer.Weights[j]
+					} else {
+						sum += input * weights[k] * cached.Factor
+					}
+				}
+			}
+			values[j] = activation(sum)
+		}
+		if i == last {
+			copy(outputs, values)
+		} else {
+			inputs = values
+		}
+	}
+}
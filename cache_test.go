@@ -0,0 +1,69 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testNetworks are real networks with power of two and other widths, deep, and dense layers
+func testNetworks() []RealNetwork {
+	dense := testRealNetwork(4, 4, 3)
+	for i, layer := range dense {
+		dense[i].Dense, dense[i].Weights = true, make([]float32, len(layer.Biases)*layer.Columns)
+		for j := range dense[i].Weights {
+			dense[i].Weights[j] = float32(j%5)/5 - .4
+		}
+	}
+	mixed := testRealNetwork(4, 6, 3)
+	mixed[0].Dense, mixed[0].Weights = true, make([]float32, 6*4)
+	for j := range mixed[0].Weights {
+		mixed[0].Weights[j] = float32(j%3)/3 - .3
+	}
+	return []RealNetwork{
+		testRealNetwork(4, 4, 3),
+		testRealNetwork(4, 5, 3),
+		testRealNetwork(6, 7, 3),
+		testRealNetwork(4, 8, 6, 5, 3),
+		dense,
+		mixed,
+	}
+}
+
+func TestCachedInference(t *testing.T) {
+	for i, network := range testNetworks() {
+		data, cached := SyntheticDataset(network[0].Columns, 3, 30, 1), NewCachedNetwork(network)
+		outputs, cachedOutputs := make([]float32, 3), make([]float32, 3)
+		for _, sample := range data.Samples {
+			network.Inference(sample.Inputs, outputs)
+			cached.Inference(sample.Inputs, cachedOutputs)
+			if !reflect.DeepEqual(outputs, cachedOutputs) {
+				t.Fatalf("network %d: cached outputs %v differ from the outputs %v", i, cachedOutputs, outputs)
+			}
+		}
+		if f, cachedF := Fitness(network, data.Samples), CachedFitness(network, data.Samples); f != cachedF {
+			t.Fatalf("network %d: cached fitness %f differs from the fitness %f", i, cachedF, f)
+		}
+	}
+}
+
+func TestInferenceBatch(t *testing.T) {
+	for i, network := range testNetworks() {
+		data := SyntheticDataset(network[0].Columns, 3, 30, 1)
+		inputs, outputs := make([][]float32, len(data.Samples)), make([][]float32, len(data.Samples))
+		for j, sample := range data.Samples {
+			inputs[j], outputs[j] = sample.Inputs, make([]float32, 3)
+		}
+		network.InferenceBatch(inputs, outputs)
+		expected := make([]float32, 3)
+		for j, input := range inputs {
+			network.Inference(input, expected)
+			if !reflect.DeepEqual(outputs[j], expected) {
+				t.Fatalf("network %d: batch outputs %v of sample %d differ from the outputs %v", i, outputs[j], j, expected)
+			}
+		}
+	}
+}
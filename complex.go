@@ -5,50 +5,111 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
-	"math/bits"
 	"math/cmplx"
-	"sort"
-
-	"github.com/pointlander/datum/iris"
 )
 
 // ComplexLayer is a complex neural network layer
 type ComplexLayer struct {
-	Columns int
-	Weights []complex64
-	Biases  []complex64
-	Rand    Rand
+	Columns    int
+	Weights    []complex64
+	Biases     []complex64
+	Rand       Rand
+	Dense      bool
+	Activation string
 }
 
 // ComplexNetwork is a complex neural network
 type ComplexNetwork []ComplexLayer
 
+type complexLayerJSON struct {
+	Columns    int
+	Weights    [][2]float32
+	Biases     [][2]float32
+	Rand       Rand
+	Dense      bool
+	Activation string
+}
+
+func toPairs(values []complex64) [][2]float32 {
+	pairs := make([][2]float32, len(values))
+	for i, value := range values {
+		pairs[i] = [2]float32{real(value), imag(value)}
+	}
+	return pairs
+}
+
+func fromPairs(pairs [][2]float32) []complex64 {
+	values := make([]complex64, len(pairs))
+	for i, pair := range pairs {
+		values[i] = complex(pair[0], pair[1])
+	}
+	return values
+}
+
+// MarshalJSON encodes the complex values of a layer as [real, imaginary] pairs
+func (l ComplexLayer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(complexLayerJSON{
+		Columns:    l.Columns,
+		Weights:    toPairs(l.Weights),
+		Biases:     toPairs(l.Biases),
+		Rand:       l.Rand,
+		Dense:      l.Dense,
+		Activation: l.Activation,
+	})
+}
+
+// UnmarshalJSON decodes a layer with complex values encoded as [real, imaginary] pairs
+func (l *ComplexLayer) UnmarshalJSON(data []byte) error {
+	var layer complexLayerJSON
+	err := json.Unmarshal(data, &layer)
+	if err != nil {
+		return err
+	}
+	l.Columns = layer.Columns
+	l.Weights = fromPairs(layer.Weights)
+	l.Biases = fromPairs(layer.Biases)
+	l.Rand = layer.Rand
+	l.Dense = layer.Dense
+	l.Activation = layer.Activation
+	return nil
+}
+
 // Inference performs inference on a neural network
 func (n ComplexNetwork) Inference(inputs, outputs []complex64) {
 	last := len(n) - 1
 	for i, layer := range n {
-		rnd := layer.Rand
+		activation := LookupComplexActivation(layer.Activation)
+		rnd := NewSource(layer.Rand)
 		columns := len(outputs)
 		if i < len(n)-1 {
 			columns = n[i+1].Columns
 		}
-		mask, values, factor :=
-			uint32((1<<bits.TrailingZeros(uint(layer.Columns)))-1),
+		values, row, factor :=
 			make([]complex64, columns),
+			make([]complex64, len(inputs)),
 			float32(math.Sqrt(2/float64(columns)))
-		for j, weight := range layer.Weights {
-			sum, index := layer.Biases[j], rnd.Uint32()&mask
+		for j, bias := range layer.Biases {
+			sum := bias
+			if layer.Dense {
+				for k, input := range inputs {
+					sum += input * layer.Weights[j*layer.Columns+k]
+				}
+				values[j] = activation(sum)
+				continue
+			}
+			index := int(rnd.Uint32() % uint32(layer.Columns))
+			MaterializeComplexRow(rnd, row, index)
 			for k, input := range inputs {
-				if k == int(index) {
-					sum += input * weight
+				if k == index {
+					sum += input * layer.Weights[j]
 				} else {
-					sum += input * complex((2*rnd.Float32()-1)*factor, (2*rnd.Float32()-1)*factor)
+					sum += input * complex(real(row[k])*factor, imag(row[k])*factor)
 				}
 			}
-			e := complex64(cmplx.Exp(complex128(sum)))
-			values[j] = e / (e + 1)
+			values[j] = activation(sum)
 		}
 		if i == last {
 			copy(outputs, values)
@@ -58,15 +119,92 @@ func (n ComplexNetwork) Inference(inputs, outputs []complex64) {
 	}
 }
 
+// Decision picks the predicted class from the outputs of a complex network
+type Decision func(outputs []complex64) int
+
+// MagnitudeDecision returns the index of the output with the largest magnitude, discarding the phase, breaking ties like Argmax
+func MagnitudeDecision(outputs []complex64) int {
+	return ArgmaxComplex(outputs)
+}
+
+// RealDecision returns the index of the output with the largest projection onto the real axis, breaking ties like Argmax
+// Unlike the magnitude the projection depends on the phase, an output pointing away from the real axis scores low
+func RealDecision(outputs []complex64) int {
+	projections := make([]float32, len(outputs))
+	for j, output := range outputs {
+		projections[j] = real(output)
+	}
+	return Argmax(projections)
+}
+
+// Decisions are the complex network decision rules by name
+var Decisions = map[string]Decision{
+	"magnitude": MagnitudeDecision,
+	"real":      RealDecision,
+}
+
+// ComplexDecision is the decision rule the complex network predicts with
+var ComplexDecision Decision = MagnitudeDecision
+
+// PredictComplex returns the index of the output of the network picked by the complex decision rule
+func (n ComplexNetwork) PredictComplex(inputs []complex64) int {
+	outputs := make([]complex64, len(n[len(n)-1].Biases))
+	n.Inference(inputs, outputs)
+	return ComplexDecision(outputs)
+}
+
+// NumParameters counts the learned weights and biases of the network, two for the real and imaginary parts of each
+func (n ComplexNetwork) NumParameters() int {
+	count := 0
+	for _, layer := range n {
+		count += 2 * (len(layer.Weights) + len(layer.Biases))
+	}
+	return count
+}
+
+// Validate checks that the network is well formed
+func (n ComplexNetwork) Validate() error {
+	if len(n) == 0 {
+		return fmt.Errorf("network has no layers")
+	}
+	previous := 0
+	for i, layer := range n {
+		if err := validateColumns(i, layer.Columns, previous); err != nil {
+			return err
+		}
+		rows := len(layer.Biases)
+		if rows == 0 {
+			return fmt.Errorf("layer %d has no rows", i)
+		}
+		weights := rows
+		if layer.Dense {
+			weights = rows * layer.Columns
+		}
+		if len(layer.Weights) != weights {
+			return fmt.Errorf("layer %d has %d weights, expected %d for %d rows", i, len(layer.Weights), weights, rows)
+		}
+		if err := validateRand(i, layer.Rand); err != nil {
+			return err
+		}
+		if err := validateActivation(i, layer.Activation); err != nil {
+			return err
+		}
+		previous = rows
+	}
+	return nil
+}
+
 // Copy copies a network
 func (n ComplexNetwork) Copy() ComplexNetwork {
 	var network ComplexNetwork
 	for _, layer := range n {
 		l := ComplexLayer{
-			Columns: layer.Columns,
-			Weights: make([]complex64, len(layer.Weights)),
-			Biases:  make([]complex64, len(layer.Biases)),
-			Rand:    layer.Rand,
+			Columns:    layer.Columns,
+			Weights:    make([]complex64, len(layer.Weights)),
+			Biases:     make([]complex64, len(layer.Biases)),
+			Rand:       layer.Rand,
+			Dense:      layer.Dense,
+			Activation: layer.Activation,
 		}
 		copy(l.Weights, layer.Weights)
 		copy(l.Biases, layer.Biases)
@@ -75,74 +213,241 @@ func (n ComplexNetwork) Copy() ComplexNetwork {
 	return network
 }
 
+// EffectiveWeights materializes the weights layer i uses for an input of length inputs, a row of inputs weights per neuron
+func (n ComplexNetwork) EffectiveWeights(i, inputs int) []complex64 {
+	layer := n[i]
+	rows := len(layer.Biases)
+	if layer.Dense {
+		weights := make([]complex64, rows*inputs)
+		for j := 0; j < rows; j++ {
+			for k := 0; k < inputs && k < layer.Columns; k++ {
+				weights[j*inputs+k] = layer.Weights[j*layer.Columns+k]
+			}
+		}
+		return weights
+	}
+	rnd := NewSource(layer.Rand)
+	columns := len(n[len(n)-1].Biases)
+	if i < len(n)-1 {
+		columns = n[i+1].Columns
+	}
+	weights, factor :=
+		make([]complex64, rows*inputs),
+		float32(math.Sqrt(2/float64(columns)))
+	for j, weight := range layer.Weights {
+		index, row := int(rnd.Uint32()%uint32(layer.Columns)), weights[j*inputs:(j+1)*inputs]
+		MaterializeComplexRow(rnd, row, index)
+		for k := range row {
+			if k == index {
+				row[k] = weight
+			} else {
+				row[k] = complex(real(row[k])*factor, imag(row[k])*factor)
+			}
+		}
+	}
+	return weights
+}
+
+// WeightStats computes the statistics of the magnitudes of the effective weights of layer i for an input of length inputs
+func (n ComplexNetwork) WeightStats(i, inputs int) WeightStats {
+	weights := n.EffectiveWeights(i, inputs)
+	magnitudes := make([]float32, len(weights))
+	for j, weight := range weights {
+		magnitudes[j] = float32(cmplx.Abs(complex128(weight)))
+	}
+	return NewWeightStats(magnitudes)
+}
+
+// SquaredNorm is the sum of the squared magnitudes of the learned weights of the network, the biases are not penalized
+func (n ComplexNetwork) SquaredNorm() float32 {
+	sum := float32(0)
+	for _, layer := range n {
+		for _, weight := range layer.Weights {
+			sum += real(weight)*real(weight) + imag(weight)*imag(weight)
+		}
+	}
+	return sum
+}
+
+// Encoding encodes the real features of a sample as the inputs of a complex network
+type Encoding struct {
+	// Inputs is the number of inputs for a number of features
+	Inputs func(features int) int
+	// Encode encodes the features into the inputs
+	Encode func(inputs []complex64, features []float32)
+}
+
+// RealEncoding uses each feature as the real part of an input, leaving the imaginary parts zero
+var RealEncoding = Encoding{
+	Inputs: func(features int) int {
+		return features
+	},
+	Encode: func(inputs []complex64, features []float32) {
+		for k, value := range features {
+			inputs[k] = complex(value, 0)
+		}
+	},
+}
+
+// PairEncoding pairs consecutive features into the real and imaginary parts of an input
+// The imaginary part of the last input is zero for an odd number of features
+var PairEncoding = Encoding{
+	Inputs: func(features int) int {
+		return (features + 1) / 2
+	},
+	Encode: func(inputs []complex64, features []float32) {
+		for k := range inputs {
+			var imaginary float32
+			if 2*k+1 < len(features) {
+				imaginary = features[2*k+1]
+			}
+			inputs[k] = complex(features[2*k], imaginary)
+		}
+	},
+}
+
+// PhaseEncoding encodes each feature as the phase of a unit input, e^(i*feature)
+var PhaseEncoding = Encoding{
+	Inputs: func(features int) int {
+		return features
+	},
+	Encode: func(inputs []complex64, features []float32) {
+		for k, value := range features {
+			sin, cos := math.Sincos(float64(value))
+			inputs[k] = complex(float32(cos), float32(sin))
+		}
+	},
+}
+
+// Encodings are the complex input encodings by name
+var Encodings = map[string]Encoding{
+	"real":  RealEncoding,
+	"pair":  PairEncoding,
+	"phase": PhaseEncoding,
+}
+
+// ComplexEncoding is the encoding of the inputs of the complex network
+var ComplexEncoding = RealEncoding
+
+// ComplexAccuracyOnDataset computes the per class precision, recall, and F1 score of a complex network on a data set
+func ComplexAccuracyOnDataset(n ComplexNetwork, data Dataset) Metrics {
+	inputs, matrix := make([]complex64, ComplexEncoding.Inputs(data.Features)), NewConfusionMatrix(data.Labels)
+	for _, sample := range data.Samples {
+		ComplexEncoding.Encode(inputs, sample.Inputs)
+		matrix.Add(sample.Label, n.PredictComplex(inputs))
+	}
+	return matrix.Metrics()
+}
+
+// ComplexModel is the complex network model
+type ComplexModel struct{}
+
+// Train trains the model
+func (ComplexModel) Train(seed int) float64 {
+	return ComplexNetworkModel(seed)
+}
+
 // ComplexNetworkModel is the complex network
 func ComplexNetworkModel(seed int) float64 {
+	_, quality := ComplexNetworkModelBest(seed)
+	return quality
+}
+
+// ComplexNetworkModelBest is the complex network that returns the best network
+func ComplexNetworkModelBest(seed int) (ComplexNetwork, float64) {
+	network, _, quality := complexNetworkModel(seed)
+	return network, quality
+}
+
+// ComplexNetworkModelHistory is the complex network that returns the best fitness of each generation
+func ComplexNetworkModelHistory(seed int) ([]float32, float64) {
+	_, history, quality := complexNetworkModel(seed)
+	return history, quality
+}
+
+// complexNetworkModel trains the complex network returning the best network, the best fitness of each generation, and the quality
+func complexNetworkModel(seed int) (ComplexNetwork, []float32, float64) {
 	rnd := Rand(LFSRInit + seed)
-	type Genome struct {
-		Network ComplexNetwork
-		Fitness float32
-	}
+	train, test := LoadData()
 	var genomes []Genome
 	addNetwork := func(i int) {
+		sizes := Architecture(ComplexEncoding.Inputs(train.Features), train.Classes)
 		var network ComplexNetwork
-		layer := ComplexLayer{
-			Columns: 4,
-			Weights: make([]complex64, 4),
-			Biases:  make([]complex64, 4),
-			Rand:    Rand(LFSRInit + i + seed + NumGenomes),
-		}
-		factor := float32(math.Sqrt(2 / float64(4)))
-		for i := range layer.Weights {
-			layer.Weights[i] = complex((2*rnd.Float32()-1)*factor, (2*rnd.Float32()-1)*factor)
-		}
-		network = append(network, layer)
-
-		layer = ComplexLayer{
-			Columns: 4,
-			Weights: make([]complex64, 3),
-			Biases:  make([]complex64, 3),
-			Rand:    Rand(LFSRInit + i + seed + 2*NumGenomes),
-		}
-		factor = float32(math.Sqrt(2 / float64(3)))
-		for i := range layer.Weights {
-			layer.Weights[i] = complex((2*rnd.Float32()-1)*factor, (2*rnd.Float32()-1)*factor)
+		for l := 1; l < len(sizes); l++ {
+			weights := sizes[l]
+			if *DenseFlag {
+				weights *= sizes[l-1]
+			}
+			layer := ComplexLayer{
+				Columns:    sizes[l-1],
+				Weights:    make([]complex64, weights),
+				Biases:     make([]complex64, sizes[l]),
+				Rand:       LayerSeed(seed, i, l),
+				Dense:      *DenseFlag,
+				Activation: *ActivationName,
+			}
+			factor := float32(math.Sqrt(2 / float64(sizes[l])))
+			for i := range layer.Weights {
+				layer.Weights[i] = complex((2*rnd.Float32()-1)*factor, (2*rnd.Float32()-1)*factor)
+			}
+			network = append(network, layer)
 		}
-		network = append(network, layer)
 
 		genomes = append(genomes, Genome{
 			Network: network,
 		})
 	}
-	for i := 0; i < NumGenomes; i++ {
+	for i := 0; i < *Genomes; i++ {
 		addNetwork(i)
 	}
+	genomes = Resume(genomes)
 
-	datum, err := iris.Load()
-	if err != nil {
-		panic(err)
-	}
-
-	inputs, outputs := make([]complex64, 4), make([]complex64, 3)
-	get := func() int {
-		for {
-			for i, genome := range genomes {
-				if rnd.Float32() > genome.Fitness {
-					return i
-				}
+	inputs := make([]complex64, ComplexEncoding.Inputs(train.Features))
+	batchRnd := Rand(LFSRInit + seed + 3*(*Genomes))
+	batch := Batch(train.Samples, *BatchSize, &batchRnd)
+	current := 0
+	genomes, history := EvolveHistory(Evolution{
+		Population:      *Genomes,
+		Elite:           *Elite,
+		Generations:     *Generations,
+		Crossovers:      Offspring(*NumCrossovers),
+		Mutations:       Offspring(*NumMutations),
+		MaxEvaluations:  *MaxEvaluations,
+		Context:         Context,
+		Rand:            &rnd,
+		Selection:       ParentSelection,
+		Samples:         len(batch),
+		Timing:          *Timing,
+		Verbose:         *Verbose,
+		CheckpointEvery: *CheckpointEvery,
+		ShareSigma:      float32(*ShareSigma),
+		Checkpoint:      CheckpointPopulation,
+		Generation: func(generation int, genomes []Genome) {
+			current = generation
+			if *BatchSize > 0 && generation > 0 {
+				batch = Batch(train.Samples, *BatchSize, &batchRnd)
 			}
-		}
-	}
-	i := 0
-	for {
-		for j, genome := range genomes {
-			sum := complex64(0)
-			for _, flower := range datum.Fisher {
-				for k, value := range flower.Measures {
-					inputs[k] = complex(float32(value), 0)
+			if *ShuffleFlag {
+				batch = Shuffle(batch, &rnd)
+			}
+		},
+		Fitness: func(network interface{}) float32 {
+			inputs, outputs, sum :=
+				make([]complex64, ComplexEncoding.Inputs(train.Features)), make([]complex64, train.Classes), complex64(0)
+			for _, sample := range batch {
+				ComplexEncoding.Encode(inputs, sample.Inputs)
+				network.(ComplexNetwork).Inference(inputs, outputs)
+				if ComplexLoss != nil {
+					magnitudes, expected := make([]float32, train.Classes), make([]float32, train.Classes)
+					for l, output := range outputs {
+						magnitudes[l] = float32(cmplx.Abs(complex128(output)))
+					}
+					expected[sample.Label] = 1
+					sum += complex(ComplexLoss.Compute(expected, magnitudes)/NormalizingLoss(ComplexLoss, train.Classes), 0)
+					continue
 				}
-				genome.Network.Inference(inputs, outputs)
-				expected := make([]complex64, 3)
-				expected[iris.Labels[flower.Label]] = 1
+				expected := make([]complex64, train.Classes)
+				expected[sample.Label] = 1
 				loss := complex64(0)
 				for l, output := range outputs {
 					diff := expected[l] - output
@@ -151,39 +456,24 @@ func ComplexNetworkModel(seed int) float64 {
 				loss = complex64(cmplx.Sqrt(complex128(loss)))
 				sum += loss
 			}
-			sum /= complex(float32(len(datum.Fisher)), 0) * complex(float32(math.Sqrt(3)), 0)
-			genomes[j].Fitness = float32(cmplx.Abs(complex128(sum)))
-		}
-		sort.Slice(genomes, func(i, j int) bool {
-			if math.IsNaN(float64(genomes[i].Fitness)) {
-				return false
-			}
-			if math.IsNaN(float64(genomes[j].Fitness)) {
-				return true
+			if ComplexLoss != nil {
+				sum /= complex(float32(len(batch)), 0)
+			} else {
+				sum /= complex(float32(len(batch)), 0) * complex(float32(math.Sqrt(float64(train.Classes))), 0)
 			}
-			return genomes[i].Fitness < genomes[j].Fitness
-		})
-		genomes = genomes[:NumGenomes]
-		i++
-		if i > 127 {
-			break
-		}
-
-		for i := 0; i < 256; i++ {
-			a, b := get(), get()
-			layer, vector, valueA, valueB :=
-				rnd.Uint32()&1, rnd.Uint32()&1, rnd.Uint32()&3, rnd.Uint32()&3
+			return Penalize(float32(cmplx.Abs(complex128(sum))), network.(ComplexNetwork))
+		},
+		Crossover: func(a, b interface{}) []interface{} {
+			layer := rnd.IntN(len(a.(ComplexNetwork)))
 			networkA, networkB :=
-				genomes[a].Network.Copy(), genomes[b].Network.Copy()
+				a.(ComplexNetwork).Copy(), b.(ComplexNetwork).Copy()
 			layerA, layerB := networkA[layer], networkB[layer]
-			if layer == 1 {
-				for valueA > 2 {
-					valueA = rnd.Uint32() & 3
-				}
-				for valueB > 2 {
-					valueB = rnd.Uint32() & 3
-				}
+			vector := rnd.Uint32() & 1
+			sizeA, sizeB := len(layerA.Weights), len(layerB.Weights)
+			if vector != 0 {
+				sizeA, sizeB = len(layerA.Biases), len(layerB.Biases)
 			}
+			valueA, valueB := rnd.IntN(sizeA), rnd.IntN(sizeB)
 			if vector == 0 {
 				layerA.Weights[valueA], layerB.Weights[valueB] =
 					layerB.Weights[valueB], layerA.Weights[valueA]
@@ -191,63 +481,57 @@ func ComplexNetworkModel(seed int) float64 {
 				layerA.Biases[valueA], layerB.Biases[valueB] =
 					layerB.Biases[valueB], layerA.Biases[valueA]
 			}
-			genomes = append(genomes, Genome{
-				Network: networkA,
-			})
-			genomes = append(genomes, Genome{
-				Network: networkB,
-			})
-		}
-
-		for i := 0; i < NumGenomes; i++ {
-			layer, vector, value, part :=
-				rnd.Uint32()&1, rnd.Uint32()&1, rnd.Uint32()&3, rnd.Uint32()&1
-			network := genomes[i].Network.Copy()
-			l := network[layer]
-			if layer == 1 {
-				for value > 2 {
-					value = rnd.Uint32() & 3
-				}
+			return []interface{}{networkA, networkB}
+		},
+		Mutate: func(n interface{}) interface{} {
+			network := n.(ComplexNetwork).Copy()
+			l := network[rnd.IntN(len(network))]
+			vector := rnd.Uint32() & 1
+			size := len(l.Weights)
+			if vector != 0 {
+				size = len(l.Biases)
 			}
+			value, part := rnd.IntN(size), rnd.Uint32()&1
 			if vector == 0 {
 				if part == 0 {
-					l.Weights[value] += complex(((2 * rnd.Float32()) - 1), 0)
+					l.Weights[value] += complex(MutationScale(current)*((2*rnd.Float32())-1), 0)
 				} else {
-					l.Weights[value] += complex(0, ((2 * rnd.Float32()) - 1))
+					l.Weights[value] += complex(0, MutationScale(current)*((2*rnd.Float32())-1))
 				}
 			} else {
 				if part == 0 {
-					l.Biases[value] += complex(((2 * rnd.Float32()) - 1), 0)
+					l.Biases[value] += complex(MutationScale(current)*((2*rnd.Float32())-1), 0)
 				} else {
-					l.Biases[value] += complex(0, ((2 * rnd.Float32()) - 1))
+					l.Biases[value] += complex(0, MutationScale(current)*((2*rnd.Float32())-1))
 				}
 			}
-			genomes = append(genomes, Genome{
-				Network: network,
-			})
-		}
-	}
+			return network
+		},
+	}, genomes)
+	SaveFinalPopulation(genomes)
 
-	network := genomes[0].Network
-	misses, total := 0, 0
-	for _, flower := range datum.Fisher {
-		for k, value := range flower.Measures {
-			inputs[k] = complex(float32(value), 0)
-		}
-		network.Inference(inputs, outputs)
-		max, index := float32(0), 0
-		for j, output := range outputs {
-			out := float32(cmplx.Abs(complex128(output)))
-			if out > max {
-				max, index = out, j
-			}
-		}
-		if index != iris.Labels[flower.Label] {
+	network := genomes[0].Network.(ComplexNetwork)
+	misses, total, matrix := 0, 0, NewConfusionMatrix(test.Labels)
+	for _, sample := range test.Samples {
+		ComplexEncoding.Encode(inputs, sample.Inputs)
+		index := network.PredictComplex(inputs)
+		if index != sample.Label {
 			misses++
 		}
+		matrix.Add(sample.Label, index)
 		total++
 	}
 	quality := float64(misses) / float64(total)
-	fmt.Println(genomes[0].Fitness, quality)
-	return quality
+	Println(genomes[0].Fitness, quality)
+	PrintEnsembleQuality(genomes, test.Samples)
+	if *Params {
+		Println("parameters", network.NumParameters())
+	}
+	if *ConfusionFlag {
+		fmt.Fprint(Output, matrix)
+	}
+	if *MetricsFlag {
+		fmt.Fprint(Output, matrix.Metrics())
+	}
+	return network, history, quality
 }
@@ -0,0 +1,66 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncodings(t *testing.T) {
+	features := []float32{1, 2, 3}
+	if n := PairEncoding.Inputs(3); n != 2 {
+		t.Fatalf("pair encoding of 3 features has %d inputs, expected 2", n)
+	}
+	inputs := make([]complex64, 2)
+	PairEncoding.Encode(inputs, features)
+	if expected := []complex64{1 + 2i, 3}; !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("pair encoding is %v, expected %v", inputs, expected)
+	}
+	inputs = make([]complex64, 3)
+	RealEncoding.Encode(inputs, features)
+	if expected := []complex64{1, 2, 3}; RealEncoding.Inputs(3) != 3 || !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("real encoding is %v, expected %v", inputs, expected)
+	}
+	PhaseEncoding.Encode(inputs, []float32{0, math.Pi / 2, math.Pi})
+	for k, expected := range []complex64{1, 1i, -1} {
+		if d := inputs[k] - expected; math.Hypot(float64(real(d)), float64(imag(d))) > 1e-6 {
+			t.Errorf("phase encoding of feature %d is %v, expected %v", k, inputs[k], expected)
+		}
+	}
+}
+
+func TestDecisions(t *testing.T) {
+	// the second output has the largest magnitude but points away from the real axis
+	outputs := []complex64{.5 + .1i, -.1 + .9i, .6}
+	if index := MagnitudeDecision(outputs); index != 1 {
+		t.Errorf("magnitude decision is %d, expected 1", index)
+	}
+	if index := RealDecision(outputs); index != 2 {
+		t.Errorf("real decision is %d, expected 2", index)
+	}
+	if index := RealDecision([]complex64{.5i, .5, .5}); index != 1 {
+		t.Errorf("real decision of a tie is %d, expected the first 1", index)
+	}
+}
+
+func TestComplexValidate(t *testing.T) {
+	if err := goldenComplex().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, change := range map[string]func(n ComplexNetwork) ComplexNetwork{
+		"no layers":  func(n ComplexNetwork) ComplexNetwork { return nil },
+		"columns":    func(n ComplexNetwork) ComplexNetwork { n[1].Columns = 5; return n },
+		"weights":    func(n ComplexNetwork) ComplexNetwork { n[0].Weights = n[0].Weights[:3]; return n },
+		"rows":       func(n ComplexNetwork) ComplexNetwork { n[1].Biases, n[1].Weights = nil, nil; return n },
+		"zero seed":  func(n ComplexNetwork) ComplexNetwork { n[1].Rand = 0; return n },
+		"activation": func(n ComplexNetwork) ComplexNetwork { n[0].Activation = "step"; return n },
+	} {
+		if err := change(goldenComplex()).Validate(); err == nil {
+			t.Errorf("network with invalid %s is valid", name)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfusionMatrix counts the predicted classes of each actual class
+type ConfusionMatrix struct {
+	Labels []string
+	Counts [][]int
+}
+
+// NewConfusionMatrix creates a confusion matrix for the labeled classes
+func NewConfusionMatrix(labels []string) ConfusionMatrix {
+	counts := make([][]int, len(labels))
+	for i := range counts {
+		counts[i] = make([]int, len(labels))
+	}
+	return ConfusionMatrix{
+		Labels: labels,
+		Counts: counts,
+	}
+}
+
+// Add adds a prediction to the confusion matrix
+func (c ConfusionMatrix) Add(actual, predicted int) {
+	c.Counts[actual][predicted]++
+}
+
+// String formats the confusion matrix with actual classes as rows and predicted classes as columns
+func (c ConfusionMatrix) String() string {
+	width := 0
+	for _, label := range c.Labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s", width, "")
+	for _, label := range c.Labels {
+		fmt.Fprintf(&b, " %*s", width, label)
+	}
+	b.WriteString("\n")
+	for i, row := range c.Counts {
+		fmt.Fprintf(&b, "%*s", width, c.Labels[i])
+		for _, count := range row {
+			fmt.Fprintf(&b, " %*d", width, count)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Metrics are the per class precision, recall, and F1 score of a classifier and their macro averages
+type Metrics struct {
+	Labels         []string
+	Precision      []float64
+	Recall         []float64
+	F1             []float64
+	MacroPrecision float64
+	MacroRecall    float64
+	MacroF1        float64
+}
+
+// Metrics computes the per class precision, recall, and F1 score from the confusion matrix
+// A class that is never predicted has a precision of zero, and a class that never occurs has a recall of zero
+func (c ConfusionMatrix) Metrics() Metrics {
+	classes := len(c.Counts)
+	m := Metrics{
+		Labels:    c.Labels,
+		Precision: make([]float64, classes),
+		Recall:    make([]float64, classes),
+		F1:        make([]float64, classes),
+	}
+	for i := 0; i < classes; i++ {
+		predicted, actual := 0, 0
+		for j := 0; j < classes; j++ {
+			predicted += c.Counts[j][i]
+			actual += c.Counts[i][j]
+		}
+		if predicted > 0 {
+			m.Precision[i] = float64(c.Counts[i][i]) / float64(predicted)
+		}
+		if actual > 0 {
+			m.Recall[i] = float64(c.Counts[i][i]) / float64(actual)
+		}
+		if sum := m.Precision[i] + m.Recall[i]; sum > 0 {
+			m.F1[i] = 2 * m.Precision[i] * m.Recall[i] / sum
+		}
+		m.MacroPrecision += m.Precision[i]
+		m.MacroRecall += m.Recall[i]
+		m.MacroF1 += m.F1[i]
+	}
+	if classes > 0 {
+		m.MacroPrecision /= float64(classes)
+		m.MacroRecall /= float64(classes)
+		m.MacroF1 /= float64(classes)
+	}
+	return m
+}
+
+// String formats the metrics with a row per class followed by the macro averages
+func (m Metrics) String() string {
+	width := len("macro")
+	for _, label := range m.Labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s %9s %9s %9s\n", width, "", "precision", "recall", "f1")
+	for i, label := range m.Labels {
+		fmt.Fprintf(&b, "%*s %9.4f %9.4f %9.4f\n", width, label, m.Precision[i], m.Recall[i], m.F1[i])
+	}
+	fmt.Fprintf(&b, "%*s %9.4f %9.4f %9.4f\n", width, "macro", m.MacroPrecision, m.MacroRecall, m.MacroF1)
+	return b.String()
+}
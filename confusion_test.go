@@ -0,0 +1,39 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfusionMatrix(t *testing.T) {
+	c := NewConfusionMatrix([]string{"a", "bb", "c"})
+	for _, prediction := range [][2]int{{0, 0}, {0, 0}, {0, 1}, {1, 1}, {1, 0}, {2, 0}} {
+		c.Add(prediction[0], prediction[1])
+	}
+	expected := "    a bb  c\n a  2  1  0\nbb  1  1  0\n c  1  0  0\n"
+	if s := c.String(); s != expected {
+		t.Fatalf("confusion matrix is\n%s\nexpected\n%s", s, expected)
+	}
+	m := c.Metrics()
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-12
+	}
+	// c is never predicted, so it has a precision, recall, and F1 score of zero
+	for i, expected := range [][3]float64{{.5, 2.0 / 3, 4.0 / 7}, {.5, .5, .5}, {0, 0, 0}} {
+		if !near(m.Precision[i], expected[0]) || !near(m.Recall[i], expected[1]) || !near(m.F1[i], expected[2]) {
+			t.Errorf("class %d has a precision of %f, recall of %f, and F1 score of %f, expected %v",
+				i, m.Precision[i], m.Recall[i], m.F1[i], expected)
+		}
+	}
+	if !near(m.MacroPrecision, 1.0/3) || !near(m.MacroRecall, 7.0/18) || !near(m.MacroF1, (4.0/7+.5)/3) {
+		t.Errorf("macro averages %f, %f, and %f, expected 1/3, 7/18, and 15/42",
+			m.MacroPrecision, m.MacroRecall, m.MacroF1)
+	}
+	if m := NewConfusionMatrix(nil).Metrics(); m.MacroF1 != 0 {
+		t.Errorf("empty confusion matrix has a macro F1 score of %f, expected 0", m.MacroF1)
+	}
+}
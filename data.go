@@ -0,0 +1,276 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pointlander/datum/iris"
+)
+
+// Sample is a labeled sample of a data set
+type Sample struct {
+	Inputs []float32
+	Label  int
+}
+
+// Dataset is a data set of labeled samples
+type Dataset struct {
+	Features int
+	Classes  int
+	Labels   []string
+	Samples  []Sample
+}
+
+// Loaders are the data set loaders
+var Loaders = map[string]func() (Dataset, error){
+	"iris": LoadIris,
+	"synthetic": func() (Dataset, error) {
+		return SyntheticDataset(4, 3, 150, 1), nil
+	},
+}
+
+// SelectedClasses are the classes being trained on, all of the classes if empty
+var SelectedClasses []int
+
+// LoadIris loads the fisher iris data set
+func LoadIris() (Dataset, error) {
+	datum, err := iris.Load()
+	if err != nil {
+		return Dataset{}, err
+	}
+	dataset := Dataset{
+		Features: 4,
+		Classes:  len(iris.Labels),
+		Labels:   make([]string, len(iris.Labels)),
+	}
+	for label, class := range iris.Labels {
+		dataset.Labels[class] = label
+	}
+	for _, flower := range datum.Fisher {
+		sample := Sample{
+			Inputs: make([]float32, len(flower.Measures)),
+			Label:  iris.Labels[flower.Label],
+		}
+		for k, value := range flower.Measures {
+			sample.Inputs[k] = float32(value)
+		}
+		dataset.Samples = append(dataset.Samples, sample)
+	}
+	return dataset, nil
+}
+
+// SyntheticDataset generates a reproducible data set of samples with features inputs spread evenly across classes
+// Each class is a cluster of samples uniformly distributed around a random center, so the classes are mostly separable
+func SyntheticDataset(features, classes, samples int, seed int) Dataset {
+	rnd := Rand(LFSRInit + seed)
+	dataset := Dataset{
+		Features: features,
+		Classes:  classes,
+		Labels:   make([]string, classes),
+	}
+	centers := make([][]float32, classes)
+	for i := range centers {
+		dataset.Labels[i] = fmt.Sprintf("class%d", i)
+		centers[i] = make([]float32, features)
+		for k := range centers[i] {
+			centers[i][k] = 2*rnd.Float32() - 1
+		}
+	}
+	for i := 0; i < samples; i++ {
+		sample := Sample{
+			Inputs: make([]float32, features),
+			Label:  i % classes,
+		}
+		for k, center := range centers[sample.Label] {
+			sample.Inputs[k] = center + (2*rnd.Float32()-1)/4
+		}
+		dataset.Samples = append(dataset.Samples, sample)
+	}
+	return dataset
+}
+
+// LoadData loads the data set restricted to the selected classes and splits it into train and test sets
+// The test set is the train set if the test fraction is zero
+func LoadData() (train, test Dataset) {
+	train, test, _ = loadData()
+	return train, test
+}
+
+// loadData loads the data sets like LoadData and also returns the normalization applied to them, nil if there is none
+func loadData() (train, test Dataset, normalization *Normalization) {
+	load, ok := Loaders[*DatasetName]
+	if !ok {
+		panic(fmt.Errorf("unknown data set %s", *DatasetName))
+	}
+	dataset, err := load()
+	if err != nil {
+		panic(err)
+	}
+	if len(SelectedClasses) > 0 {
+		dataset, err = SelectClasses(dataset, SelectedClasses)
+		if err != nil {
+			panic(err)
+		}
+	}
+	rnd := Rand(LFSRInit)
+	train, test = SplitDataset(dataset, *TestFrac, &rnd)
+	if *Normalize {
+		n := NewNormalization(train)
+		train, test, normalization = n.Apply(train), n.Apply(test), &n
+	}
+	if len(test.Samples) == 0 {
+		test = train
+	}
+	return train, test, normalization
+}
+
+// Normalization is the per feature mean and standard deviation of a data set
+type Normalization struct {
+	Mean []float32
+	Std  []float32
+}
+
+// NewNormalization computes the per feature mean and standard deviation of a data set
+func NewNormalization(dataset Dataset) Normalization {
+	mean, variance := make([]float64, dataset.Features), make([]float64, dataset.Features)
+	for _, sample := range dataset.Samples {
+		for k, value := range sample.Inputs {
+			mean[k] += float64(value)
+		}
+	}
+	n := float64(len(dataset.Samples))
+	for k := range mean {
+		mean[k] /= n
+	}
+	for _, sample := range dataset.Samples {
+		for k, value := range sample.Inputs {
+			diff := float64(value) - mean[k]
+			variance[k] += diff * diff
+		}
+	}
+	normalization := Normalization{
+		Mean: make([]float32, dataset.Features),
+		Std:  make([]float32, dataset.Features),
+	}
+	for k := range mean {
+		normalization.Mean[k] = float32(mean[k])
+		normalization.Std[k] = float32(math.Sqrt(variance[k] / n))
+	}
+	return normalization
+}
+
+// Apply returns a copy of the data set with the z-score of each feature as inputs
+// A feature with zero standard deviation is only centered
+func (n Normalization) Apply(dataset Dataset) Dataset {
+	normalized := dataset
+	normalized.Samples = make([]Sample, len(dataset.Samples))
+	for i, sample := range dataset.Samples {
+		inputs := make([]float32, len(sample.Inputs))
+		for k, value := range sample.Inputs {
+			inputs[k] = value - n.Mean[k]
+			if n.Std[k] > 0 {
+				inputs[k] /= n.Std[k]
+			}
+		}
+		normalized.Samples[i] = Sample{
+			Inputs: inputs,
+			Label:  sample.Label,
+		}
+	}
+	return normalized
+}
+
+// SplitDataset shuffles the data set and splits it into train and test sets with fraction of the samples in the test set
+// The split is stratified so that each class has the same proportion in both sets
+func SplitDataset(dataset Dataset, fraction float64, rnd *Rand) (train, test Dataset) {
+	return StratifiedSplit(dataset, fraction, rnd)
+}
+
+// SelectClasses restricts the data set to the given classes, remapping them to 0..n-1
+// The classes must be distinct classes of the data set
+func SelectClasses(dataset Dataset, classes []int) (Dataset, error) {
+	seen := make(map[int]bool)
+	for _, class := range classes {
+		if class < 0 || class >= dataset.Classes {
+			return Dataset{}, fmt.Errorf("class %d out of range [0, %d)", class, dataset.Classes)
+		}
+		if seen[class] {
+			return Dataset{}, fmt.Errorf("class %d is selected more than once", class)
+		}
+		seen[class] = true
+	}
+	selected := Dataset{
+		Features: dataset.Features,
+		Classes:  len(classes),
+		Labels:   make([]string, len(classes)),
+	}
+	for i, class := range classes {
+		selected.Labels[i] = dataset.Labels[class]
+	}
+	for _, sample := range dataset.Samples {
+		for i, class := range classes {
+			if sample.Label == class {
+				selected.Samples = append(selected.Samples, Sample{
+					Inputs: sample.Inputs,
+					Label:  i,
+				})
+			}
+		}
+	}
+	return selected, nil
+}
+
+// StratifiedSplit splits the data set into train and test sets with fraction of each class in the test set
+func StratifiedSplit(dataset Dataset, fraction float64, rnd *Rand) (train, test Dataset) {
+	train.Features, train.Classes, train.Labels = dataset.Features, dataset.Classes, dataset.Labels
+	test.Features, test.Classes, test.Labels = dataset.Features, dataset.Classes, dataset.Labels
+	classes := make(map[int][]Sample)
+	var labels []int
+	for _, sample := range dataset.Samples {
+		if _, ok := classes[sample.Label]; !ok {
+			labels = append(labels, sample.Label)
+		}
+		classes[sample.Label] = append(classes[sample.Label], sample)
+	}
+	for _, label := range labels {
+		samples := classes[label]
+		for i := len(samples) - 1; i > 0; i-- {
+			j := rnd.IntN(i + 1)
+			samples[i], samples[j] = samples[j], samples[i]
+		}
+		size := int(math.Round(fraction * float64(len(samples))))
+		test.Samples = append(test.Samples, samples[:size]...)
+		train.Samples = append(train.Samples, samples[size:]...)
+	}
+	return train, test
+}
+
+// Batch returns size samples chosen at random without replacement
+// All of the samples are returned if size is not positive or is not smaller than the number of samples
+func Batch(samples []Sample, size int, rnd *Rand) []Sample {
+	if size <= 0 || size >= len(samples) {
+		return samples
+	}
+	batch := make([]Sample, len(samples))
+	copy(batch, samples)
+	for i := 0; i < size; i++ {
+		j := i + rnd.IntN(len(batch)-i)
+		batch[i], batch[j] = batch[j], batch[i]
+	}
+	return batch[:size]
+}
+
+// Shuffle returns a copy of the samples in an order permuted with a Fisher-Yates shuffle
+func Shuffle(samples []Sample, rnd *Rand) []Sample {
+	shuffled := make([]Sample, len(samples))
+	copy(shuffled, samples)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := rnd.IntN(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return shuffled
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// sampleKeys returns a key identifying each sample by its inputs and label
+func sampleKeys(samples []Sample) map[string]int {
+	keys := make(map[string]int)
+	for _, sample := range samples {
+		keys[fmt.Sprint(sample.Inputs, sample.Label)]++
+	}
+	return keys
+}
+
+func TestSplitDataset(t *testing.T) {
+	dataset, err := LoadIris()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := Rand(LFSRInit)
+	train, test := SplitDataset(dataset, .2, &rnd)
+	again := Rand(LFSRInit)
+	train2, test2 := SplitDataset(dataset, .2, &again)
+	if !reflect.DeepEqual(train, train2) || !reflect.DeepEqual(test, test2) {
+		t.Fatal("split is not reproducible for a fixed seed")
+	}
+	if len(test.Samples) != 30 || len(train.Samples) != 120 {
+		t.Fatalf("split has %d train and %d test samples, expected 120 and 30", len(train.Samples), len(test.Samples))
+	}
+	counts := make([]int, dataset.Classes)
+	for _, sample := range test.Samples {
+		counts[sample.Label]++
+	}
+	for class, count := range counts {
+		if count != 10 {
+			t.Errorf("class %d has %d test samples, expected 10", class, count)
+		}
+	}
+	all, trainKeys, testKeys := sampleKeys(dataset.Samples), sampleKeys(train.Samples), sampleKeys(test.Samples)
+	for key, count := range all {
+		if trainKeys[key]+testKeys[key] != count {
+			t.Errorf("sample %s is in the train set %d times and the test set %d times, expected %d in total",
+				key, trainKeys[key], testKeys[key], count)
+		}
+	}
+	for key := range testKeys {
+		if trainKeys[key] > 0 && all[key] == 1 {
+			t.Errorf("sample %s is in both the train and test sets", key)
+		}
+	}
+}
+
+func TestSplitDatasetNoTest(t *testing.T) {
+	dataset := SyntheticDataset(4, 3, 30, 1)
+	rnd := Rand(LFSRInit)
+	train, test := SplitDataset(dataset, 0, &rnd)
+	if len(train.Samples) != 30 || len(test.Samples) != 0 {
+		t.Fatalf("split has %d train and %d test samples, expected 30 and 0", len(train.Samples), len(test.Samples))
+	}
+}
+
+func TestSelectClasses(t *testing.T) {
+	dataset, err := LoadIris()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := SelectClasses(dataset, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected.Classes != 2 || !reflect.DeepEqual(selected.Labels, dataset.Labels[1:]) {
+		t.Fatalf("selected %d classes %v, expected 2 classes %v", selected.Classes, selected.Labels, dataset.Labels[1:])
+	}
+	if len(selected.Samples) != 100 {
+		t.Fatalf("selected %d samples, expected 100", len(selected.Samples))
+	}
+	excluded := make(map[string]bool)
+	for _, sample := range dataset.Samples {
+		if sample.Label == 0 {
+			excluded[fmt.Sprint(sample.Inputs)] = true
+		}
+	}
+	for _, sample := range selected.Samples {
+		if sample.Label < 0 || sample.Label > 1 {
+			t.Fatalf("sample has label %d, expected 0 or 1", sample.Label)
+		}
+		if excluded[fmt.Sprint(sample.Inputs)] {
+			t.Fatalf("sample %v of the excluded class was selected", sample.Inputs)
+		}
+	}
+	if sizes := Architecture(selected.Features, selected.Classes); sizes[len(sizes)-1] != 2 {
+		t.Fatalf("architecture %v, expected 2 outputs", sizes)
+	}
+}
+
+func TestSelectClassesInvalid(t *testing.T) {
+	dataset := SyntheticDataset(4, 3, 30, 1)
+	for _, classes := range [][]int{{5}, {-1}, {1, 1}, {0, 2, 0}} {
+		if _, err := SelectClasses(dataset, classes); err == nil {
+			t.Errorf("classes %v were selected, expected an error", classes)
+		}
+	}
+}
+
+func TestSyntheticDataset(t *testing.T) {
+	dataset := SyntheticDataset(5, 4, 40, 2)
+	if dataset.Features != 5 || dataset.Classes != 4 || len(dataset.Labels) != 4 || len(dataset.Samples) != 40 {
+		t.Fatalf("data set has %d features, %d classes, %d labels, and %d samples, expected 5, 4, 4, and 40",
+			dataset.Features, dataset.Classes, len(dataset.Labels), len(dataset.Samples))
+	}
+	counts := make([]int, 4)
+	for _, sample := range dataset.Samples {
+		if len(sample.Inputs) != 5 {
+			t.Fatalf("sample has %d inputs, expected 5", len(sample.Inputs))
+		}
+		counts[sample.Label]++
+	}
+	for class, count := range counts {
+		if count != 10 {
+			t.Errorf("class %d has %d samples, expected 10", class, count)
+		}
+	}
+	if !reflect.DeepEqual(dataset, SyntheticDataset(5, 4, 40, 2)) {
+		t.Fatal("data set is not reproducible for a fixed seed")
+	}
+	if reflect.DeepEqual(dataset, SyntheticDataset(5, 4, 40, 3)) {
+		t.Fatal("data sets of different seeds are the same")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	samples := SyntheticDataset(4, 3, 30, 1).Samples
+	rnd := Rand(LFSRInit)
+	batch := Batch(samples, 10, &rnd)
+	if len(batch) != 10 {
+		t.Fatalf("batch has %d samples, expected 10", len(batch))
+	}
+	all := sampleKeys(samples)
+	for key, count := range sampleKeys(batch) {
+		if count > all[key] {
+			t.Fatalf("sample %s is in the batch %d times, expected at most %d", key, count, all[key])
+		}
+	}
+	for _, size := range []int{0, -1, 30, 40} {
+		if batch := Batch(samples, size, &rnd); len(batch) != 30 {
+			t.Errorf("batch of size %d has %d samples, expected all 30", size, len(batch))
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	samples := SyntheticDataset(4, 3, 30, 1).Samples
+	rnd := Rand(LFSRInit)
+	shuffled := Shuffle(samples, &rnd)
+	if reflect.DeepEqual(shuffled, samples) {
+		t.Fatal("shuffle kept the order")
+	}
+	if !reflect.DeepEqual(sampleKeys(shuffled), sampleKeys(samples)) {
+		t.Fatal("shuffle changed the samples")
+	}
+	if !reflect.DeepEqual(samples, SyntheticDataset(4, 3, 30, 1).Samples) {
+		t.Fatal("shuffle changed the order of its argument")
+	}
+	again := Rand(LFSRInit)
+	if !reflect.DeepEqual(shuffled, Shuffle(samples, &again)) {
+		t.Fatal("shuffle is not reproducible for a fixed seed")
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	dataset := SyntheticDataset(4, 3, 30, 1)
+	normalized := NewNormalization(dataset).Apply(dataset)
+	check := NewNormalization(normalized)
+	for k := range check.Mean {
+		if math.Abs(float64(check.Mean[k])) > 1e-5 || math.Abs(float64(check.Std[k]-1)) > 1e-5 {
+			t.Errorf("normalized feature %d has a mean of %f and deviation of %f, expected 0 and 1", k, check.Mean[k], check.Std[k])
+		}
+	}
+	constant := Dataset{Features: 1, Samples: []Sample{{Inputs: []float32{2}}, {Inputs: []float32{2}}}}
+	if value := NewNormalization(constant).Apply(constant).Samples[0].Inputs[0]; value != 0 {
+		t.Errorf("constant feature is normalized to %f, expected 0", value)
+	}
+}
+
+func TestLoadData(t *testing.T) {
+	defer func(name string, fraction float64) {
+		*DatasetName, *TestFrac = name, fraction
+	}(*DatasetName, *TestFrac)
+	*DatasetName, *TestFrac = "synthetic", 0
+	train, test := LoadData()
+	if len(train.Samples) != 150 || !reflect.DeepEqual(train, test) {
+		t.Fatalf("split has %d train and %d test samples, expected the 150 train samples to be the test set",
+			len(train.Samples), len(test.Samples))
+	}
+	*TestFrac = .2
+	train, test = LoadData()
+	if len(train.Samples) != 120 || len(test.Samples) != 30 {
+		t.Fatalf("split has %d train and %d test samples, expected 120 and 30", len(train.Samples), len(test.Samples))
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// PopulationDiversity computes the mean pairwise weight distance between networks of the same shape
+func PopulationDiversity(networks []RealNetwork) float32 {
+	sum, count := float32(0), 0
+	for i := range networks {
+		for j := i + 1; j < len(networks); j++ {
+			if !networks[i].SameShape(networks[j]) {
+				continue
+			}
+			sum += WeightDistance(networks[i], networks[j])
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float32(count)
+}
+
+// GenomeDiversity computes the diversity of a population of real, shared, random, or complex networks
+// The diversity of real, shared, and complex networks is the mean pairwise distance between their learned parameters,
+// and the diversity of random networks is the fraction of genomes that differ from all of the genomes before them
+// A population of identical genomes has zero diversity
+func GenomeDiversity(genomes []Genome) float32 {
+	if len(genomes) < 2 {
+		return 0
+	}
+	switch genomes[0].Network.(type) {
+	case RealNetwork:
+		networks := make([]RealNetwork, len(genomes))
+		for i, genome := range genomes {
+			networks[i] = genome.Network.(RealNetwork)
+		}
+		return PopulationDiversity(networks)
+	case SharedNetwork:
+		vectors := make([][]float32, len(genomes))
+		for i, genome := range genomes {
+			for _, layer := range genome.Network.(SharedNetwork) {
+				vectors[i] = append(vectors[i], layer.Weights...)
+			}
+		}
+		return meanPairwiseDistance(vectors)
+	case ComplexNetwork:
+		vectors := make([][]float32, len(genomes))
+		for i, genome := range genomes {
+			for _, layer := range genome.Network.(ComplexNetwork) {
+				vectors[i] = appendComplex(appendComplex(vectors[i], layer.Weights), layer.Biases)
+			}
+		}
+		return meanPairwiseDistance(vectors)
+	case RandomNetwork:
+		distinct := make(map[string]bool)
+		for _, genome := range genomes {
+			distinct[fmt.Sprint(genome.Network)] = true
+		}
+		return float32(len(distinct)-1) / float32(len(genomes)-1)
+	}
+	return 0
+}
+
+// meanPairwiseDistance computes the mean euclidean distance between the pairs of vectors of the same length
+func meanPairwiseDistance(vectors [][]float32) float32 {
+	sum, count := float32(0), 0
+	for i := range vectors {
+		for j := i + 1; j < len(vectors); j++ {
+			if len(vectors[i]) != len(vectors[j]) {
+				continue
+			}
+			distance := float32(0)
+			for k, value := range vectors[i] {
+				diff := value - vectors[j][k]
+				distance += diff * diff
+			}
+			sum += float32(math.Sqrt(float64(distance)))
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float32(count)
+}
+
+// GenomeDistance computes the euclidean distance between the learned parameters of two networks of the same type
+// Random networks are only close if they have the same seeds, then the distance is between their scales
+// The distance is infinite between networks of different shapes
+func GenomeDistance(a, b interface{}) float32 {
+	var x, y []float32
+	switch n := a.(type) {
+	case RealNetwork:
+		m := b.(RealNetwork)
+		if !n.SameShape(m) {
+			return float32(math.Inf(1))
+		}
+		return WeightDistance(n, m)
+	case SharedNetwork:
+		for _, layer := range n {
+			x = append(x, layer.Weights...)
+		}
+		for _, layer := range b.(SharedNetwork) {
+			y = append(y, layer.Weights...)
+		}
+	case ComplexNetwork:
+		for _, layer := range n {
+			x = appendComplex(appendComplex(x, layer.Weights), layer.Biases)
+		}
+		for _, layer := range b.(ComplexNetwork) {
+			y = appendComplex(appendComplex(y, layer.Weights), layer.Biases)
+		}
+	case RandomNetwork:
+		m := b.(RandomNetwork)
+		if len(n) != len(m) {
+			return float32(math.Inf(1))
+		}
+		for i := range n {
+			if n[i].Rand != m[i].Rand {
+				return float32(math.Inf(1))
+			}
+			x, y = append(x, n[i].Scale), append(y, m[i].Scale)
+		}
+	}
+	if len(x) != len(y) {
+		return float32(math.Inf(1))
+	}
+	distance := float32(0)
+	for k, value := range x {
+		diff := value - y[k]
+		distance += diff * diff
+	}
+	return float32(math.Sqrt(float64(distance)))
+}
+
+// appendComplex appends the real and imaginary parts of the values to parameters
+func appendComplex(parameters []float32, values []complex64) []float32 {
+	for _, value := range values {
+		parameters = append(parameters, real(value), imag(value))
+	}
+	return parameters
+}
+
+// ShareFitness multiplies the fitness of each genome by its niche count, so that crowded genomes have worse fitness
+// The niche count is the sum of 1 - distance/sigma over the genomes closer than sigma, including the genome itself
+func ShareFitness(genomes []Genome, sigma float32) {
+	niches := make([]float32, len(genomes))
+	for i := range genomes {
+		niches[i]++
+		for j := i + 1; j < len(genomes); j++ {
+			distance := GenomeDistance(genomes[i].Network, genomes[j].Network)
+			if distance < sigma {
+				share := 1 - distance/sigma
+				niches[i] += share
+				niches[j] += share
+			}
+		}
+	}
+	for i := range genomes {
+		genomes[i].Fitness *= niches[i]
+	}
+}
+
+// WriteDiversity writes the diversity of each generation to a csv file
+func WriteDiversity(file string, diversity []float32) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	writer := csv.NewWriter(out)
+	for i, d := range diversity {
+		err := writer.Write([]string{
+			strconv.Itoa(i),
+			strconv.FormatFloat(float64(d), 'f', -1, 32),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
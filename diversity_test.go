@@ -0,0 +1,90 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenomeDiversity(t *testing.T) {
+	for _, network := range []interface{}{goldenReal(), goldenShared(), goldenRandom(), goldenComplex()} {
+		same := []Genome{{Network: network}, {Network: network}, {Network: network}}
+		if diversity := GenomeDiversity(same); diversity != 0 {
+			t.Errorf("identical %T population has a diversity of %f, expected 0", network, diversity)
+		}
+	}
+	// the genomes of testGenomes differ by 1 in a single weight
+	if diversity := GenomeDiversity(testGenomes(3)); math.Abs(float64(diversity)-4/3.0) > 1e-6 {
+		t.Errorf("real population has a diversity of %f, expected 4/3", diversity)
+	}
+	random := goldenRandom()
+	other := random.Copy()
+	other[0].Rand++
+	if diversity := GenomeDiversity([]Genome{{Network: random}, {Network: other}, {Network: random}}); diversity != .5 {
+		t.Errorf("random population has a diversity of %f, expected .5", diversity)
+	}
+	if diversity := GenomeDiversity(testGenomes(1)); diversity != 0 {
+		t.Errorf("population of one genome has a diversity of %f, expected 0", diversity)
+	}
+}
+
+func TestShareFitness(t *testing.T) {
+	genomes := testGenomes(3)
+	genomes[2].Network.(RealNetwork)[0].Weights[0] = 100
+	for i := range genomes {
+		genomes[i].Fitness = .5
+	}
+	ShareFitness(genomes, 2)
+	// the first two genomes are 1 apart, so each shares half of its fitness with the other
+	if genomes[0].Fitness != .75 || genomes[1].Fitness != .75 || genomes[2].Fitness != .5 {
+		t.Fatalf("shared fitness %f, %f, and %f, expected .75, .75, and .5", genomes[0].Fitness, genomes[1].Fitness, genomes[2].Fitness)
+	}
+}
+
+func TestGenomeDistance(t *testing.T) {
+	if distance := GenomeDistance(goldenReal(), testRealNetwork(4, 5, 3)); !math.IsInf(float64(distance), 1) {
+		t.Errorf("distance between different shapes is %f, expected infinity", distance)
+	}
+	shared := goldenShared()
+	other := shared.Copy()
+	other[0].Weights[0] += 3
+	other[1].Weights[1] += 4
+	if distance := GenomeDistance(shared, other); distance != 5 {
+		t.Errorf("shared distance is %f, expected 5", distance)
+	}
+	complex := goldenComplex()
+	otherComplex := complex.Copy()
+	otherComplex[0].Biases[0] += 3 + 4i
+	if distance := GenomeDistance(complex, otherComplex); math.Abs(float64(distance)-5) > 1e-6 {
+		t.Errorf("complex distance is %f, expected 5", distance)
+	}
+	random := goldenRandom()
+	scaled := random.Copy()
+	scaled[1].Scale = 3
+	if distance := GenomeDistance(random, scaled); distance != 2 {
+		t.Errorf("random distance is %f, expected 2", distance)
+	}
+	scaled[0].Rand++
+	if distance := GenomeDistance(random, scaled); !math.IsInf(float64(distance), 1) {
+		t.Errorf("distance between random networks with different seeds is %f, expected infinity", distance)
+	}
+}
+
+func TestWriteDiversity(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "diversity.csv")
+	if err := WriteDiversity(file, []float32{1.5, .25}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "0,1.5\n1,0.25\n"; string(data) != expected {
+		t.Fatalf("diversity file is %q, expected %q", data, expected)
+	}
+}
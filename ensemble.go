@@ -0,0 +1,96 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// WeightedEnsemblePredict averages the outputs of the networks by weight and returns the predicted class and its output
+func WeightedEnsemblePredict(nets []RealNetwork, weights []float32, inputs []float32) (int, float32) {
+	outputs, sum, total := make([]float32, nets[0].Outputs()), make([]float32, nets[0].Outputs()), float32(0)
+	for i, n := range nets {
+		n.Inference(inputs, outputs)
+		for j, output := range outputs {
+			sum[j] += weights[i] * output
+		}
+		total += weights[i]
+	}
+	if total > 0 {
+		for j := range sum {
+			sum[j] /= total
+		}
+	}
+	index := Argmax(sum)
+	return index, sum[index]
+}
+
+// AccuracyWeights computes ensemble weights from the accuracy of each network on a validation set
+func AccuracyWeights(nets []RealNetwork, validation []Sample) []float32 {
+	weights := make([]float32, len(nets))
+	for i, n := range nets {
+		weights[i] = float32(1 - Evaluate(n, validation))
+	}
+	return weights
+}
+
+// EnsembleQuality computes the fraction of the samples misclassified by the sum of the outputs of the networks
+// The networks are real, shared, random, or complex networks, the complex outputs are summed before the complex decision rule
+func EnsembleQuality(networks []interface{}, samples []Sample) (float64, error) {
+	classifiers := make([]Classifier, len(networks))
+	for i, network := range networks {
+		classifier, err := NewClassifier(network)
+		if err != nil {
+			return 0, err
+		}
+		classifiers[i] = classifier
+	}
+	misses := 0
+	for _, sample := range samples {
+		var sum []float32
+		var complexSum []complex64
+		for _, classifier := range classifiers {
+			_, outputs := classifier.Inference(sample.Inputs)
+			switch o := outputs.(type) {
+			case []float32:
+				if sum == nil {
+					sum = make([]float32, len(o))
+				}
+				for j, output := range o {
+					sum[j] += output
+				}
+			case []complex64:
+				if complexSum == nil {
+					complexSum = make([]complex64, len(o))
+				}
+				for j, output := range o {
+					complexSum[j] += output
+				}
+			}
+		}
+		index := 0
+		if complexSum != nil {
+			index = ComplexDecision(complexSum)
+		} else {
+			index = Argmax(sum)
+		}
+		if index != sample.Label {
+			misses++
+		}
+	}
+	return float64(misses) / float64(len(samples)), nil
+}
+
+// PrintEnsembleQuality prints the quality of the ensemble of the best genomes on the samples if the ensemble size is set
+func PrintEnsembleQuality(genomes []Genome, samples []Sample) {
+	if *EnsembleSize <= 0 {
+		return
+	}
+	var networks []interface{}
+	for i := 0; i < *EnsembleSize && i < len(genomes); i++ {
+		networks = append(networks, genomes[i].Network)
+	}
+	quality, err := EnsembleQuality(networks, samples)
+	if err != nil {
+		panic(err)
+	}
+	Println("ensemble", quality)
+}
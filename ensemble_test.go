@@ -0,0 +1,71 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWeightedEnsemblePredict(t *testing.T) {
+	a, b := goldenReal(), testRealNetwork(4, 5, 3)
+	outputs := make([]float32, 3)
+	a.Inference(testInputs, outputs)
+	index, output := WeightedEnsemblePredict([]RealNetwork{a, b}, []float32{1, 0}, testInputs)
+	if expected := Argmax(outputs); index != expected || output != outputs[expected] {
+		t.Fatalf("prediction %d with output %f, expected %d with %f of the only weighted network",
+			index, output, expected, outputs[expected])
+	}
+	// zero weights leave every averaged output at zero, so the first class is predicted
+	if index, output := WeightedEnsemblePredict([]RealNetwork{a}, []float32{0}, testInputs); index != 0 || output != 0 {
+		t.Fatalf("prediction of zero weights is %d with output %f, expected 0 with 0", index, output)
+	}
+}
+
+func TestAccuracyWeights(t *testing.T) {
+	data, nets := SyntheticDataset(4, 3, 30, 1).Samples, []RealNetwork{goldenReal(), testRealNetwork(4, 5, 3)}
+	weights := AccuracyWeights(nets, data)
+	for i, n := range nets {
+		if expected := float32(1 - Evaluate(n, data)); weights[i] != expected {
+			t.Errorf("network %d has a weight of %f, expected %f", i, weights[i], expected)
+		}
+	}
+}
+
+func TestEnsembleQuality(t *testing.T) {
+	data := SyntheticDataset(4, 3, 30, 1).Samples
+	// an ensemble of one network has the quality of the network
+	for _, network := range []interface{}{goldenReal(), goldenShared(), goldenRandom(), goldenComplex()} {
+		classifier, err := NewClassifier(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		misses := 0
+		for _, sample := range data {
+			if index, _ := classifier.Inference(sample.Inputs); index != sample.Label {
+				misses++
+			}
+		}
+		quality, err := EnsembleQuality([]interface{}{network}, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := float64(misses) / float64(len(data)); quality != expected {
+			t.Errorf("%T ensemble of one has a quality of %f, expected %f", network, quality, expected)
+		}
+	}
+	if _, err := EnsembleQuality([]interface{}{1}, data); err == nil {
+		t.Fatal("ensemble of an unknown network type has no error")
+	}
+	// networks of the same type are summed, so an ensemble of a network with itself has its quality
+	double, err := EnsembleQuality([]interface{}{goldenReal(), goldenReal()}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, _ := EnsembleQuality([]interface{}{goldenReal()}, data)
+	if !reflect.DeepEqual(double, single) {
+		t.Fatalf("ensemble of a network with itself has a quality of %f, expected %f", double, single)
+	}
+}
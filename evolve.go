@@ -0,0 +1,274 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Context stops the evolution of the models early when it is done
+var Context = context.Background()
+
+// Genome is a network and its fitness
+type Genome struct {
+	Network interface{}
+	Fitness float32
+	elite   bool
+}
+
+// Evolution configures the genetic algorithm
+type Evolution struct {
+	// Population is the number of genomes kept after each generation
+	Population int
+	// Generations is the number of generations evaluated
+	Generations int
+	// Crossovers is the number of crossovers per generation
+	Crossovers int
+	// Mutations is the number of mutations per generation, the survivors are mutated in order of fitness
+	Mutations int
+	// Elite is the number of best genomes carried into the next generation unmodified
+	Elite int
+	// MaxEvaluations is the maximum number of fitness evaluations, zero for no maximum
+	MaxEvaluations int
+	// Context stops the evolution after the current generation when it is done, nil to never stop
+	Context context.Context
+	// Rand is the random number generator used for selection
+	Rand *Rand
+	// Selection selects the parents of the crossovers from the evaluated survivors,
+	// nil for the fitness proportional selection over the whole population including the offspring added so far
+	Selection Selection
+	// Samples is the number of inferences per fitness evaluation, used for timing
+	Samples int
+	// Timing prints the duration and throughput of each generation and of the whole run
+	Timing bool
+	// Verbose prints the best, mean, and worst fitness of each generation
+	Verbose bool
+	// Fitness computes the fitness of a network, lower is better
+	// Fitness is called concurrently, so it must not share scratch buffers between calls
+	Fitness func(network interface{}) float32
+	// Crossover produces the children of two parents
+	Crossover func(a, b interface{}) []interface{}
+	// Mutate produces a mutated copy of a network
+	Mutate func(network interface{}) interface{}
+	// Generation is called before the fitness of a generation is computed
+	Generation func(generation int, genomes []Genome)
+	// Selected is called after a generation is sorted and truncated
+	Selected func(generation int, genomes []Genome)
+	// CheckpointEvery is the number of generations between calls to Checkpoint, zero to never call it
+	CheckpointEvery int
+	// Checkpoint saves the population after a generation is selected
+	Checkpoint func(generation int, genomes []Genome)
+	// ShareSigma is the distance within which genomes share their fitness, zero for no fitness sharing
+	ShareSigma float32
+	// SnapshotEvery is the number of generations between snapshots of the best genome, zero for no snapshots
+	SnapshotEvery int
+	// Copy copies a network for a snapshot, so later changes to the population don't change the snapshot
+	Copy func(network interface{}) interface{}
+}
+
+// Decay scales the initial mutation strength at a generation out of generations
+type Decay func(strength float32, generation, generations int) float32
+
+// NoDecay keeps the mutation strength constant
+func NoDecay(strength float32, generation, generations int) float32 {
+	return strength
+}
+
+// LinearDecay decreases the mutation strength linearly towards zero at the end of the generations
+func LinearDecay(strength float32, generation, generations int) float32 {
+	return strength * (1 - float32(generation)/float32(generations))
+}
+
+// ExponentialDecay decreases the mutation strength exponentially to one percent of the strength at the end of the generations
+func ExponentialDecay(strength float32, generation, generations int) float32 {
+	return strength * float32(math.Pow(.01, float64(generation)/float64(generations)))
+}
+
+// Decays are the mutation strength decays by name
+var Decays = map[string]Decay{
+	"none":   NoDecay,
+	"linear": LinearDecay,
+	"exp":    ExponentialDecay,
+}
+
+// MutationDecay is the decay of the mutation strength
+var MutationDecay Decay = NoDecay
+
+// MutationScale is the scale of the mutation deltas at a generation
+func MutationScale(generation int) float32 {
+	return MutationDecay(float32(*MutationStrength), generation, *Generations)
+}
+
+// Offspring is the number of crossovers or mutations per generation given by a flag, the population size if it is negative
+func Offspring(n int) int {
+	if n < 0 {
+		return *Genomes
+	}
+	return n
+}
+
+// Evolve evolves a population of genomes and returns the population sorted by fitness
+func Evolve(e Evolution, genomes []Genome) []Genome {
+	genomes, _ = EvolveHistory(e, genomes)
+	return genomes
+}
+
+// EvolveHistory evolves a population of genomes and returns the population sorted by fitness
+// and the best fitness of each generation
+func EvolveHistory(e Evolution, genomes []Genome) ([]Genome, []float32) {
+	genomes, history, _ := EvolveSnapshots(e, genomes)
+	return genomes, history
+}
+
+// EvolveSnapshots evolves a population of genomes and returns the population sorted by fitness,
+// the best fitness of each generation, and a copy of the best genome every SnapshotEvery generations in order
+func EvolveSnapshots(e Evolution, genomes []Genome) ([]Genome, []float32, []Genome) {
+	rnd, survivors := e.Rand, 0
+	get := func() int {
+		if e.Selection == nil {
+			// the offspring are not evaluated yet, so their fitness is zero and they are almost always accepted
+			return ProportionalSelection(genomes, rnd)
+		}
+		return e.Selection(genomes[:survivors], rnd)
+	}
+	i, evaluations, done, start := 0, 0, false, time.Now()
+	history := make([]float32, 0, e.Generations)
+	var snapshots []Genome
+	for {
+		elite := 0
+		if i > 0 {
+			elite = e.Elite
+		}
+		for j := range genomes {
+			genomes[j].elite = j < elite
+		}
+		if e.Generation != nil {
+			e.Generation(i, genomes[elite:])
+		}
+		if e.MaxEvaluations > 0 && evaluations+len(genomes) >= e.MaxEvaluations {
+			genomes, done = genomes[:e.MaxEvaluations-evaluations], true
+		}
+		generation := time.Now()
+		evaluateFitness(e.Fitness, genomes)
+		if e.ShareSigma > 0 {
+			ShareFitness(genomes, e.ShareSigma)
+		}
+		evaluations += len(genomes)
+		if e.Timing {
+			duration := time.Since(generation).Seconds()
+			fmt.Fprintf(Output, "generation=%d seconds=%f inferences_per_second=%f\n",
+				i, duration, float64(len(genomes)*e.Samples)/duration)
+		}
+		sortGenomes(genomes)
+		if len(genomes) > e.Population {
+			survivors, k := genomes[:e.Population], e.Population-1
+			for _, genome := range genomes[e.Population:] {
+				if !genome.elite {
+					continue
+				}
+				for survivors[k].elite {
+					k--
+				}
+				survivors[k] = genome
+				k--
+			}
+			genomes = survivors
+			sortGenomes(genomes)
+		}
+		survivors = len(genomes)
+		history = append(history, genomes[0].Fitness)
+		if e.Verbose {
+			mean := float32(0)
+			for _, genome := range genomes {
+				mean += genome.Fitness
+			}
+			mean /= float32(len(genomes))
+			Println("generation", i, "best", genomes[0].Fitness, "mean", mean, "worst", genomes[len(genomes)-1].Fitness,
+				"diversity", GenomeDiversity(genomes))
+		}
+		if e.Selected != nil {
+			e.Selected(i, genomes)
+		}
+		if e.Checkpoint != nil && e.CheckpointEvery > 0 && (i+1)%e.CheckpointEvery == 0 {
+			e.Checkpoint(i, genomes)
+		}
+		if e.Copy != nil && e.SnapshotEvery > 0 && (i+1)%e.SnapshotEvery == 0 {
+			snapshots = append(snapshots, Genome{
+				Network: e.Copy(genomes[0].Network),
+				Fitness: genomes[0].Fitness,
+			})
+		}
+		i++
+		if e.Context != nil && e.Context.Err() != nil {
+			done = true
+		}
+		if i >= e.Generations || done {
+			break
+		}
+
+		if e.Crossover != nil {
+			for i := 0; i < e.Crossovers; i++ {
+				a, b := get(), get()
+				for _, child := range e.Crossover(genomes[a].Network, genomes[b].Network) {
+					genomes = append(genomes, Genome{
+						Network: child,
+					})
+				}
+			}
+		}
+
+		if e.Mutate != nil {
+			for i := 0; i < e.Mutations; i++ {
+				genomes = append(genomes, Genome{
+					Network: e.Mutate(genomes[i%survivors].Network),
+				})
+			}
+		}
+	}
+	if e.Timing {
+		duration := time.Since(start).Seconds()
+		fmt.Fprintf(Output, "generations=%d seconds=%f inferences_per_second=%f\n",
+			i, duration, float64(evaluations*e.Samples)/duration)
+	}
+	return genomes, history, snapshots
+}
+
+// evaluateFitness computes the fitness of the genomes across runtime.NumCPU() workers
+func evaluateFitness(fitness func(network interface{}) float32, genomes []Genome) {
+	indexes, wg := make(chan int, len(genomes)), sync.WaitGroup{}
+	for j := range genomes {
+		indexes <- j
+	}
+	close(indexes)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range indexes {
+				genomes[j].Fitness = fitness(genomes[j].Network)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// sortGenomes sorts the genomes by fitness with NaN fitness last
+func sortGenomes(genomes []Genome) {
+	sort.Slice(genomes, func(i, j int) bool {
+		if math.IsNaN(float64(genomes[i].Fitness)) {
+			return false
+		}
+		if math.IsNaN(float64(genomes[j].Fitness)) {
+			return true
+		}
+		return genomes[i].Fitness < genomes[j].Fitness
+	})
+}
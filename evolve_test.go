@@ -0,0 +1,246 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testEvolution configures the evolution of a population of small real networks on a synthetic data set
+func testEvolution(rnd *Rand) Evolution {
+	data := SyntheticDataset(4, 3, 30, 1).Samples
+	return Evolution{
+		Population:  8,
+		Generations: 6,
+		Crossovers:  4,
+		Mutations:   4,
+		Rand:        rnd,
+		Fitness: func(network interface{}) float32 {
+			return Fitness(network.(RealNetwork), data)
+		},
+		Crossover: func(a, b interface{}) []interface{} {
+			networkA, networkB := a.(RealNetwork).Copy(), b.(RealNetwork).Copy()
+			networkA[0].Weights[0], networkB[0].Weights[0] = networkB[0].Weights[0], networkA[0].Weights[0]
+			return []interface{}{networkA, networkB}
+		},
+		Mutate: func(network interface{}) interface{} {
+			mutated := network.(RealNetwork).Copy()
+			mutated[rnd.IntN(len(mutated))].Biases[0] += 2*rnd.Float32() - 1
+			return mutated
+		},
+		Copy: func(network interface{}) interface{} {
+			return network.(RealNetwork).Copy()
+		},
+	}
+}
+
+func TestEvolveDeterministic(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	genomes, history := EvolveHistory(testEvolution(&rnd), testGenomes(8))
+	again := Rand(LFSRInit)
+	genomes2, history2 := EvolveHistory(testEvolution(&again), testGenomes(8))
+	if !reflect.DeepEqual(genomes, genomes2) || !reflect.DeepEqual(history, history2) {
+		t.Fatal("evolution is not reproducible for a fixed seed")
+	}
+	if len(genomes) != 8 {
+		t.Fatalf("%d genomes, expected the population of 8", len(genomes))
+	}
+	for i := 1; i < len(genomes); i++ {
+		if genomes[i].Fitness < genomes[i-1].Fitness {
+			t.Fatalf("genome %d has a lower fitness than genome %d", i, i-1)
+		}
+	}
+	if len(history) != 6 {
+		t.Fatalf("history has %d generations, expected 6", len(history))
+	}
+	if history[len(history)-1] != genomes[0].Fitness {
+		t.Fatalf("last best fitness %f differs from the best genome %f", history[len(history)-1], genomes[0].Fitness)
+	}
+}
+
+func TestEvaluateFitness(t *testing.T) {
+	genomes, data := testGenomes(16), SyntheticDataset(4, 3, 30, 1).Samples
+	evaluateFitness(func(network interface{}) float32 {
+		return Fitness(network.(RealNetwork), data)
+	}, genomes)
+	for i, genome := range genomes {
+		if fitness := Fitness(genome.Network.(RealNetwork), data); genome.Fitness != fitness {
+			t.Errorf("genome %d has a fitness of %f, expected %f", i, genome.Fitness, fitness)
+		}
+	}
+}
+
+func TestEvolveElite(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	e.Elite, e.Selection = 1, TournamentSelection(2)
+	_, history := EvolveHistory(e, testGenomes(8))
+	for i := 1; i < len(history); i++ {
+		if history[i] > history[i-1] {
+			t.Fatalf("best fitness increased from %f to %f at generation %d with an elite", history[i-1], history[i], i)
+		}
+	}
+}
+
+func TestEvolveMaxEvaluations(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	var evaluations int64
+	fitness := e.Fitness
+	e.Fitness = func(network interface{}) float32 {
+		atomic.AddInt64(&evaluations, 1)
+		return fitness(network)
+	}
+	e.Generations, e.MaxEvaluations = 100, 30
+	_, history := EvolveHistory(e, testGenomes(8))
+	if evaluations != 30 {
+		t.Fatalf("%d evaluations, expected the maximum of 30", evaluations)
+	}
+	// 8 evaluations in the first generation, 20 in the second, and the remaining 2 in the third
+	if len(history) != 3 {
+		t.Fatalf("history has %d generations, expected 3", len(history))
+	}
+}
+
+func TestEvolveContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	e.Context = ctx
+	genomes, history := EvolveHistory(e, testGenomes(8))
+	if len(history) != 1 || len(genomes) != 8 {
+		t.Fatalf("canceled evolution ran %d generations with %d genomes, expected 1 generation of 8", len(history), len(genomes))
+	}
+}
+
+func TestEvolveMutations(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	mutations, mutate := 0, e.Mutate
+	e.Crossover, e.Mutations = nil, 3
+	e.Mutate = func(network interface{}) interface{} {
+		mutations++
+		return mutate(network)
+	}
+	EvolveHistory(e, testGenomes(8))
+	if mutations != 3*(e.Generations-1) {
+		t.Fatalf("%d mutations, expected 3 in each of the %d generations after the first", mutations, e.Generations-1)
+	}
+}
+
+func TestEvolveSnapshots(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	e.SnapshotEvery = 2
+	genomes, history, snapshots := EvolveSnapshots(e, testGenomes(8))
+	if len(snapshots) != 3 {
+		t.Fatalf("%d snapshots, expected 3", len(snapshots))
+	}
+	for i, snapshot := range snapshots {
+		if fitness := history[2*i+1]; snapshot.Fitness != fitness {
+			t.Errorf("snapshot %d has a fitness of %f, expected the best fitness %f of generation %d",
+				i, snapshot.Fitness, fitness, 2*i+1)
+		}
+	}
+	if !reflect.DeepEqual(snapshots[2].Network, genomes[0].Network) {
+		t.Fatal("last snapshot differs from the best genome")
+	}
+	genomes[0].Network.(RealNetwork)[0].Weights[0]++
+	if reflect.DeepEqual(snapshots[2].Network, genomes[0].Network) {
+		t.Fatal("changing the best genome changed its snapshot")
+	}
+}
+
+func TestEvolveOutput(t *testing.T) {
+	var out bytes.Buffer
+	discard(t)
+	Output = &out
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	e.Timing, e.Verbose, e.Samples = true, true, 30
+	EvolveHistory(e, testGenomes(8))
+	for _, prefix := range []string{"generation=0 seconds=", "generation 5 best", "generations=6 seconds="} {
+		if !strings.Contains(out.String(), prefix) {
+			t.Errorf("output is missing %q:\n%s", prefix, out.String())
+		}
+	}
+}
+
+func TestSortGenomesNaN(t *testing.T) {
+	nan := float32(math.NaN())
+	genomes := []Genome{{Fitness: nan}, {Fitness: .5}, {Fitness: nan}, {Fitness: .1}}
+	sortGenomes(genomes)
+	if genomes[0].Fitness != .1 || genomes[1].Fitness != .5 {
+		t.Fatalf("genomes sorted as %v, expected .1 and .5 first", genomes)
+	}
+	for _, genome := range genomes[2:] {
+		if !math.IsNaN(float64(genome.Fitness)) {
+			t.Fatalf("genomes sorted as %v, expected NaN last", genomes)
+		}
+	}
+}
+
+func TestEvolveNaN(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	e := testEvolution(&rnd)
+	fitness := e.Fitness
+	// genome 3 and the offspring that inherit its first weight have a NaN fitness
+	e.Fitness = func(network interface{}) float32 {
+		if network.(RealNetwork)[0].Weights[0] == 3 {
+			return float32(math.NaN())
+		}
+		return fitness(network)
+	}
+	genomes, history := EvolveHistory(e, testGenomes(8))
+	for i, best := range history {
+		if math.IsNaN(float64(best)) {
+			t.Fatalf("generation %d has a NaN best fitness", i)
+		}
+	}
+	if best := genomes[0]; math.IsNaN(float64(best.Fitness)) || best.Network.(RealNetwork)[0].Weights[0] == 3 {
+		t.Fatalf("best genome has a fitness of %f, expected a genome without a NaN fitness", best.Fitness)
+	}
+	nan := false
+	for i, genome := range genomes {
+		if math.IsNaN(float64(genome.Fitness)) {
+			nan = true
+		} else if nan {
+			t.Fatalf("genome %d has a fitness of %f after a NaN fitness, expected NaN last", i, genome.Fitness)
+		}
+	}
+}
+
+func TestDecays(t *testing.T) {
+	for name, decay := range Decays {
+		if strength := decay(2, 0, 10); strength != 2 {
+			t.Errorf("%s decay starts at %f, expected 2", name, strength)
+		}
+	}
+	if strength := NoDecay(2, 10, 10); strength != 2 {
+		t.Errorf("no decay ends at %f, expected 2", strength)
+	}
+	if strength := LinearDecay(2, 10, 10); strength != 0 {
+		t.Errorf("linear decay ends at %f, expected 0", strength)
+	}
+	if strength := ExponentialDecay(2, 10, 10); math.Abs(float64(strength)-.02) > 1e-6 {
+		t.Errorf("exponential decay ends at %f, expected .02", strength)
+	}
+}
+
+func TestOffspring(t *testing.T) {
+	if n := Offspring(3); n != 3 {
+		t.Errorf("3 offspring is %d", n)
+	}
+	if n := Offspring(-1); n != *Genomes {
+		t.Errorf("negative offspring is %d, expected the population size %d", n, *Genomes)
+	}
+}
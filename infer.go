@@ -0,0 +1,115 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Classifier computes the outputs of a network for the features of a sample
+type Classifier struct {
+	// Inputs is the number of inputs the network takes
+	Inputs int
+	// Encoded is the number of inputs a feature vector with a number of features is encoded as
+	Encoded func(features int) int
+	// Outputs is the number of outputs of the network
+	Outputs int
+	// Inference computes the outputs of the network, which are printed, and returns the predicted class
+	Inference func(features []float32) (int, interface{})
+}
+
+// NewClassifier creates a classifier for a real, shared, random, or complex network
+func NewClassifier(network interface{}) (Classifier, error) {
+	switch n := network.(type) {
+	case RealNetwork:
+		return Classifier{
+			Inputs:  n[0].Columns,
+			Encoded: RealEncoding.Inputs,
+			Outputs: n.Outputs(),
+			Inference: func(features []float32) (int, interface{}) {
+				outputs := make([]float32, n.Outputs())
+				n.Inference(features, outputs)
+				return Argmax(outputs), outputs
+			},
+		}, nil
+	case SharedNetwork:
+		return Classifier{
+			Inputs:  n[0].Columns,
+			Encoded: RealEncoding.Inputs,
+			Outputs: n[len(n)-1].Rows,
+			Inference: func(features []float32) (int, interface{}) {
+				outputs := make([]float32, n[len(n)-1].Rows)
+				n.Inference(features, outputs)
+				return Argmax(outputs), outputs
+			},
+		}, nil
+	case RandomNetwork:
+		return Classifier{
+			Inputs:  n[0].Columns,
+			Encoded: RealEncoding.Inputs,
+			Outputs: n[len(n)-1].Rows,
+			Inference: func(features []float32) (int, interface{}) {
+				outputs := make([]float32, n[len(n)-1].Rows)
+				n.Inference(features, outputs)
+				return Argmax(outputs), outputs
+			},
+		}, nil
+	case ComplexNetwork:
+		return Classifier{
+			Inputs:  n[0].Columns,
+			Encoded: ComplexEncoding.Inputs,
+			Outputs: len(n[len(n)-1].Biases),
+			Inference: func(features []float32) (int, interface{}) {
+				inputs, outputs :=
+					make([]complex64, n[0].Columns), make([]complex64, len(n[len(n)-1].Biases))
+				ComplexEncoding.Encode(inputs, features)
+				n.Inference(inputs, outputs)
+				return ComplexDecision(outputs), outputs
+			},
+		}, nil
+	}
+	return Classifier{}, fmt.Errorf("unknown network type %T", network)
+}
+
+// Infer classifies each comma separated feature vector read from in, printing the predicted label and the outputs
+// The features are normalized first if normalization is not nil
+func Infer(classifier Classifier, labels []string, normalization *Normalization, in io.Reader) error {
+	if len(labels) != classifier.Outputs {
+		return fmt.Errorf("network has %d outputs but there are %d labels", classifier.Outputs, len(labels))
+	}
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if inputs := classifier.Encoded(len(record)); inputs != classifier.Inputs {
+			return fmt.Errorf("feature vector %s is encoded as %d inputs, the network takes %d",
+				strings.Join(record, ","), inputs, classifier.Inputs)
+		}
+		features := make([]float32, len(record))
+		for k, field := range record {
+			value, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+			if err != nil {
+				return err
+			}
+			features[k] = float32(value)
+		}
+		if normalization != nil {
+			features = normalization.Apply(Dataset{
+				Samples: []Sample{{Inputs: features}},
+			}).Samples[0].Inputs
+		}
+		index, outputs := classifier.Inference(features)
+		fmt.Fprintln(Output, labels[index], outputs)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	var out bytes.Buffer
+	discard(t)
+	Output = &out
+	labels := []string{"setosa", "versicolor", "virginica"}
+	for _, network := range []interface{}{goldenReal(), goldenShared(), goldenRandom(), goldenComplex()} {
+		out.Reset()
+		classifier, err := NewClassifier(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Infer(classifier, labels, nil, strings.NewReader("5.1, 3.5, 1.4, 0.2\n5.1,3.5,1.4,0.2\n")); err != nil {
+			t.Fatal(err)
+		}
+		index, outputs := classifier.Inference(testInputs)
+		line := fmt.Sprintln(labels[index], outputs)
+		if expected := line + line; out.String() != expected {
+			t.Errorf("%T inference printed %q, expected %q", network, out.String(), expected)
+		}
+	}
+	classifier, _ := NewClassifier(goldenReal())
+	for name, input := range map[string]string{
+		"features":  "5.1,3.5,1.4\n",
+		"parse":     "5.1,3.5,1.4,x\n",
+		"quotation": "\"5.1,3.5,1.4,0.2\n",
+	} {
+		if err := Infer(classifier, labels, nil, strings.NewReader(input)); err == nil {
+			t.Errorf("%s error is nil", name)
+		}
+	}
+	if err := Infer(classifier, labels[:2], nil, strings.NewReader("")); err == nil {
+		t.Error("label count error is nil")
+	}
+	if _, err := NewClassifier(1); err == nil {
+		t.Error("unknown network type error is nil")
+	}
+}
+
+func TestInferNormalization(t *testing.T) {
+	var out bytes.Buffer
+	discard(t)
+	Output = &out
+	normalization := &Normalization{Mean: []float32{5, 3, 1, 0}, Std: []float32{2, 2, 2, 2}}
+	classifier, _ := NewClassifier(goldenReal())
+	if err := Infer(classifier, []string{"a", "b", "c"}, normalization, strings.NewReader("7,5,3,2\n")); err != nil {
+		t.Fatal(err)
+	}
+	index, outputs := classifier.Inference([]float32{1, 1, 1, 1})
+	if expected := fmt.Sprintln([]string{"a", "b", "c"}[index], outputs); out.String() != expected {
+		t.Fatalf("normalized inference printed %q, expected %q", out.String(), expected)
+	}
+}
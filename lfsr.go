@@ -0,0 +1,43 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+)
+
+// LFSRPeriod computes the period of a galois LFSR with the given polynomial
+// The polynomial should have the high bit set, otherwise the LFSR may never return to its initial state
+func LFSRPeriod(polynomial uint32) uint32 {
+	// https://en.wikipedia.org/wiki/Linear-feedback_shift_register
+	// https://users.ece.cmu.edu/~koopman/lfsr/index.html
+	lfsr, period := uint32(1), uint32(0)
+	for {
+		lfsr = (lfsr >> 1) ^ (-(lfsr & 1) & polynomial)
+		period++
+		if lfsr == 1 {
+			break
+		}
+	}
+	return period
+}
+
+// FindMaximalLFSR searches for a maximal period polynomial starting at start
+// At most count polynomials are checked, or until the polynomial wraps around to zero if count is zero
+// progress is called with the period of each polynomial checked
+func FindMaximalLFSR(start, count uint32, progress func(polynomial, period uint32)) (polynomial uint32, period uint32, found bool) {
+	checked := uint32(0)
+	for polynomial = start; polynomial != 0 && (count == 0 || checked < count); polynomial++ {
+		period = LFSRPeriod(polynomial)
+		if progress != nil {
+			progress(polynomial, period)
+		}
+		if period == math.MaxUint32 {
+			return polynomial, period, true
+		}
+		checked++
+	}
+	return polynomial, period, false
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLFSRPeriod(t *testing.T) {
+	// x^4 + x^3 + 1 is primitive, x^4 + x^3 + x^2 + x + 1 is not
+	if period := LFSRPeriod(0xC); period != 15 {
+		t.Errorf("period of c is %d, expected 15", period)
+	}
+	if period := LFSRPeriod(0xF); period != 5 {
+		t.Errorf("period of f is %d, expected 5", period)
+	}
+}
+
+func TestFindMaximalLFSR(t *testing.T) {
+	var checked []uint32
+	polynomial, _, found := FindMaximalLFSR(0xC, 3, func(polynomial, period uint32) {
+		checked = append(checked, polynomial)
+	})
+	if found || len(checked) != 3 || checked[0] != 0xC || checked[2] != 0xE || polynomial != 0xF {
+		t.Fatalf("checked %x and stopped at %x found %t, expected to check c, d, and e and stop at f", checked, polynomial, found)
+	}
+	if testing.Short() {
+		t.Skip("steps the lfsr through its full period")
+	}
+	polynomial, period, found := FindMaximalLFSR(LFSRMask, 1, nil)
+	if !found || polynomial != LFSRMask || period != math.MaxUint32 {
+		t.Fatalf("found %t %x with a period of %d, expected the maximal mask %x", found, polynomial, period, LFSRMask)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+)
+
+// Epsilon clamps outputs away from 0 and 1 in the cross entropy loss
+const Epsilon = 1e-7
+
+// Loss is a loss function used to compute fitness
+type Loss interface {
+	// Compute computes the loss of the outputs given the expected outputs
+	Compute(expected, outputs []float32) float32
+	// Max is the maximum loss for a one hot target, which normalizes the fitness
+	Max(numClasses int) float32
+}
+
+// LossFunc computes the loss of the outputs given the expected outputs
+type LossFunc func(expected, outputs []float32) float32
+
+// Compute computes the loss of the outputs given the expected outputs
+func (l LossFunc) Compute(expected, outputs []float32) float32 {
+	return l(expected, outputs)
+}
+
+// Max is the maximum loss for a one hot target
+func (l LossFunc) Max(numClasses int) float32 {
+	return MaxLoss(numClasses, l)
+}
+
+var (
+	// MSE is the euclidean loss
+	MSE = LossFunc(EuclideanLoss)
+	// L1 is the l1 loss
+	L1 = LossFunc(L1Loss)
+	// CrossEntropy is the cross entropy loss
+	CrossEntropy = LossFunc(CrossEntropyLoss)
+	// SoftmaxCrossEntropy is the softmax cross entropy loss
+	SoftmaxCrossEntropy = LossFunc(SoftmaxCrossEntropyLoss)
+)
+
+// Losses are the losses by name
+var Losses = map[string]Loss{
+	"rmse": MSE,
+	"l1":   L1,
+	"bce":  CrossEntropy,
+	"xent": SoftmaxCrossEntropy,
+}
+
+// FitnessLoss is the loss used to compute fitness
+var FitnessLoss Loss = MSE
+
+// ComplexLoss is the loss the complex network applies to the magnitudes of its outputs,
+// nil for the euclidean distance between the complex outputs and the expected outputs
+var ComplexLoss Loss
+
+// EuclideanLoss is the euclidean distance between the expected and actual outputs
+func EuclideanLoss(expected, outputs []float32) float32 {
+	loss := float32(0)
+	for l, output := range outputs {
+		diff := expected[l] - output
+		loss += diff * diff
+	}
+	return float32(math.Sqrt(float64(loss)))
+}
+
+// L1Loss is the sum of the absolute differences between the expected and actual outputs
+func L1Loss(expected, outputs []float32) float32 {
+	loss := float32(0)
+	for l, output := range outputs {
+		loss += float32(math.Abs(float64(expected[l] - output)))
+	}
+	return loss
+}
+
+// CrossEntropyLoss is the binary cross entropy of the outputs with the outputs clamped to [Epsilon, 1-Epsilon]
+func CrossEntropyLoss(expected, outputs []float32) float32 {
+	loss := float32(0)
+	for l, output := range outputs {
+		if output < Epsilon {
+			output = Epsilon
+		} else if output > 1-Epsilon {
+			output = 1 - Epsilon
+		}
+		loss -= expected[l]*float32(math.Log(float64(output))) +
+			(1-expected[l])*float32(math.Log(float64(1-output)))
+	}
+	return loss
+}
+
+// SoftmaxCrossEntropyLoss is the cross entropy of the softmax of the outputs
+func SoftmaxCrossEntropyLoss(expected, outputs []float32) float32 {
+	max := outputs[0]
+	for _, output := range outputs {
+		if output > max {
+			max = output
+		}
+	}
+	sum := 0.0
+	for _, output := range outputs {
+		sum += math.Exp(float64(output - max))
+	}
+	logSum, loss := float32(math.Log(sum)), float32(0)
+	for l, output := range outputs {
+		loss -= expected[l] * (output - max - logSum)
+	}
+	return loss
+}
+
+// Normed is a network with a squared norm for the l2 penalty
+type Normed interface {
+	// SquaredNorm is the sum of the squares of the learned weights of the network
+	SquaredNorm() float32
+}
+
+// Penalize adds the l2 penalty on the squared norm of a network to its fitness
+func Penalize(fitness float32, network Normed) float32 {
+	if *L2 > 0 {
+		fitness += float32(*L2) * network.SquaredNorm()
+	}
+	return fitness
+}
+
+// NormalizingLoss is the maximum loss the fitness is divided by, 1 if the maximum is not positive
+// A loss that is always zero then gives a fitness of zero instead of NaN
+func NormalizingLoss(loss Loss, numClasses int) float32 {
+	if max := loss.Max(numClasses); max > 0 {
+		return max
+	}
+	return 1
+}
+
+// MaxLoss computes the loss of maximally wrong outputs for a one hot target, which normalizes the fitness
+func MaxLoss(numClasses int, loss LossFunc) float32 {
+	expected, outputs := make([]float32, numClasses), make([]float32, numClasses)
+	expected[0] = 1
+	for i := range outputs {
+		outputs[i] = 1 - expected[i]
+	}
+	return loss(expected, outputs)
+}
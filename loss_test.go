@@ -0,0 +1,109 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZeroLossFitness(t *testing.T) {
+	defer func(loss Loss) {
+		FitnessLoss = loss
+	}(FitnessLoss)
+	FitnessLoss = LossFunc(func(expected, outputs []float32) float32 {
+		return 0
+	})
+	if max := FitnessLoss.Max(3); max != 0 {
+		t.Fatalf("max of the zero loss is %f, expected 0", max)
+	}
+	network := testRealNetwork(4, 4, 3)
+	data := SyntheticDataset(4, 3, 30, 1).Samples
+	if fitness := Fitness(network, data); fitness != 0 {
+		t.Fatalf("fitness of the zero loss is %f, expected 0", fitness)
+	}
+	if fitness := CachedFitness(network, data); fitness != 0 {
+		t.Fatalf("cached fitness of the zero loss is %f, expected 0", fitness)
+	}
+}
+
+func TestNormalizingLoss(t *testing.T) {
+	for name, loss := range Losses {
+		if max := NormalizingLoss(loss, 3); max != loss.Max(3) || max <= 0 {
+			t.Errorf("%s normalizes by %f, expected its positive max %f", name, max, loss.Max(3))
+		}
+	}
+}
+
+func TestMaxLoss(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		expected float64
+	}{
+		{"rmse", math.Sqrt(3)},
+		{"l1", 3},
+		// the wrong outputs are clamped to Epsilon and to 1-Epsilon rounded to a float32
+		{"bce", -math.Log(Epsilon) - 2*math.Log(1-float64(float32(1-Epsilon)))},
+		{"xent", 1 + math.Log(2+1/math.E)},
+	} {
+		if max := Losses[c.name].Max(3); math.Abs(float64(max)-c.expected) > 1e-4*c.expected {
+			t.Errorf("max %s loss of 3 classes is %f, expected %f", c.name, max, c.expected)
+		}
+	}
+}
+
+func TestLosses(t *testing.T) {
+	expected := []float32{0, 1, 0}
+	for name, loss := range Losses {
+		right, wrong := loss.Compute(expected, []float32{0, 1, 0}), loss.Compute(expected, []float32{1, 0, 0})
+		if right >= wrong || right < 0 {
+			t.Errorf("%s loss of the right outputs is %f and of the wrong outputs %f", name, right, wrong)
+		}
+	}
+	if loss := MSE.Compute(expected, []float32{0, 1, 0}); loss != 0 {
+		t.Errorf("euclidean loss of the right outputs is %f, expected 0", loss)
+	}
+	if loss := L1.Compute(expected, []float32{.5, .5, 0}); loss != 1 {
+		t.Errorf("l1 loss is %f, expected 1", loss)
+	}
+	if loss := CrossEntropy.Compute(expected, []float32{0, 0, 0}); math.IsInf(float64(loss), 0) {
+		t.Error("cross entropy of zero outputs is infinite")
+	}
+}
+
+func TestFitnessLoss(t *testing.T) {
+	defer func(loss Loss) {
+		FitnessLoss = loss
+	}(FitnessLoss)
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	for name, loss := range Losses {
+		FitnessLoss = loss
+		fitness, sum, outputs := Fitness(network, data), float32(0), make([]float32, 3)
+		for _, sample := range data {
+			network.Inference(sample.Inputs, outputs)
+			expected := make([]float32, 3)
+			expected[sample.Label] = 1
+			sum += loss.Compute(expected, outputs)
+		}
+		if expected := sum / (30 * loss.Max(3)); math.Abs(float64(fitness-expected)) > 1e-6 {
+			t.Errorf("%s fitness is %f, expected %f", name, fitness, expected)
+		}
+	}
+}
+
+func TestSoftmaxCrossEntropyLoss(t *testing.T) {
+	expected := []float32{0, 1, 0}
+	// the softmax of the right output is e/(2+e)
+	if loss, want := SoftmaxCrossEntropy.Compute(expected, []float32{0, 1, 0}), math.Log(2+math.E)-1; math.Abs(float64(loss)-want) > 1e-6 {
+		t.Errorf("softmax cross entropy is %f, expected %f", loss, want)
+	}
+	// the softmax doesn't change when the same value is added to every output
+	if a, b := SoftmaxCrossEntropy.Compute(expected, []float32{.2, .7, .1}), SoftmaxCrossEntropy.Compute(expected, []float32{100.2, 100.7, 100.1}); math.Abs(float64(a-b)) > 1e-5 {
+		t.Errorf("softmax cross entropy of shifted outputs is %f, expected %f", b, a)
+	}
+	if a, b := Losses["xent"].Compute(expected, []float32{.2, .7, .1}), SoftmaxCrossEntropy.Compute(expected, []float32{.2, .7, .1}); a != b {
+		t.Errorf("xent loss is %f, expected the softmax cross entropy %f", a, b)
+	}
+}
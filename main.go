@@ -5,15 +5,116 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
 )
 
 var (
 	// LFSR find lfsr
 	LFSR = flag.Bool("lfsr", false, "find a lfsr")
+	// LFSRStart is the polynomial the lfsr search starts from
+	LFSRStart = flag.String("lfsr-start", "80000000", "hex polynomial to start the lfsr search from")
+	// ActivationName is the name of the activation function of the networks
+	ActivationName = flag.String("activation", "sigmoid", "activation function: sigmoid, tanh, or relu")
+	// SelectionName is the name of the parent selection
+	SelectionName = flag.String("selection", "proportional", "parent selection: proportional or tournament")
+	// TournamentSize is the number of genomes in each tournament of the tournament selection
+	TournamentSize = flag.Int("tournament-size", 4, "number of genomes in each tournament of the tournament selection")
+	// Cache generates the random weights of the real networks once per fitness evaluation instead of once per sample
+	Cache = flag.Bool("cache", false, "generate the random weights of the real networks once per fitness evaluation")
+	// Dropout is the probability each input connection is dropped during training of the real network
+	Dropout = flag.Float64("dropout", 0, "probability each input connection is dropped during training of the real network")
+	// L2 is the weight of the penalty on the sum of the squared learned weights added to the fitness
+	L2 = flag.Float64("l2", 0, "weight of the penalty on the sum of the squared learned weights, the layer scales of the random network, added to the fitness")
+	// RefinePasses is the number of coordinate descent passes refining the best real network
+	RefinePasses = flag.Int("refine", 0, "number of coordinate descent passes refining the best real network")
+	// RefineEpsilon is the step size of the coordinate descent refinement
+	RefineEpsilon = flag.Float64("refine-eps", .01, "step size of the coordinate descent refinement")
+	// LayersSpec is the sizes of the layers of the networks from the inputs to the outputs
+	LayersSpec = flag.String("layers", "", "comma separated sizes of the layers of the networks from the inputs to the outputs, such as 4,8,8,3")
+	// DecisionName is the name of the rule the complex network picks the predicted class with
+	DecisionName = flag.String("decision", "magnitude", "complex network decision rule: magnitude or real")
+	// ResumeFile is the file of a saved population the models continue evolving from
+	ResumeFile = flag.String("resume", "", "file of a saved population to continue evolving from instead of a random population")
+	// PopulationFile is the file the final population of the models is saved to
+	PopulationFile = flag.String("save-population", "", "file to save the final population to, json if the extension is .json otherwise gob")
+	// CheckpointEvery is the number of generations between population checkpoints
+	CheckpointEvery = flag.Int("checkpoint-every", 0, "number of generations between saving the population, 0 to never save")
+	// CheckpointFile is the prefix of the population checkpoint files
+	CheckpointFile = flag.String("checkpoint", "checkpoint", "prefix of the population checkpoint files, which are named prefix_generation.gob")
+	// LossName is the name of the loss the fitness is computed with
+	LossName = flag.String("loss", "rmse", "loss the fitness is computed with: rmse, l1, bce, or xent")
+	// Normalize replaces the input features with their z-score over the training set
+	Normalize = flag.Bool("normalize", false, "normalize the input features to zero mean and unit variance over the training set")
+	// EncodingName is the name of the encoding of the features as the inputs of the complex network
+	EncodingName = flag.String("encoding", "real", "encoding of the features as complex network inputs: real, pair, or phase")
+	// Quiet discards everything the models print
+	Quiet = flag.Bool("quiet", false, "suppress all output")
+	// MutationStrength scales the random deltas added by the mutations
+	MutationStrength = flag.Float64("mut-strength", 1, "scale of the random deltas added to the weights by the mutations")
+	// MutationDecayName is the name of the decay of the mutation strength over the generations
+	MutationDecayName = flag.String("mut-decay", "none", "decay of the mutation strength over the generations: none, linear, or exp")
+	// NumCrossovers is the number of crossovers per generation
+	NumCrossovers = flag.Int("num-crossovers", -1, "number of crossovers per generation, negative for the population size")
+	// NumMutations is the number of mutations per generation
+	NumMutations = flag.Int("num-mutations", -1, "number of mutations per generation, negative for the population size")
+	// EnsembleSize is the number of best genomes whose summed outputs are evaluated as an ensemble
+	EnsembleSize = flag.Int("ensemble", 0, "evaluate an ensemble of this many of the best genomes, 0 for no ensemble")
+	// Params prints the number of learned parameters of the best network
+	Params = flag.Bool("params", false, "print the number of learned parameters of the best network")
+	// GradientEpsilon is the step of the finite difference gradient of the fitness of the best real network
+	GradientEpsilon = flag.Float64("gradient", 0, "print the finite difference gradient of the fitness of the best real network with this step, 0 to not print it")
+	// DenseFlag makes the real and complex networks use a stored weight for every input instead of one per neuron
+	DenseFlag = flag.Bool("dense", false, "use a stored weight for every input of the real and complex networks instead of random weights")
+	// Pool is the number of shared weights of each layer of the shared network
+	Pool = flag.Int("pool", 4, "number of shared weights of each layer of the shared network, a power of two")
+	// TraceFile is the file every random number used during inference is written to
+	TraceFile = flag.String("trace", "", "file to write every random number used during inference to, for debugging")
+	// ShareSigma is the distance within which genomes share their fitness
+	ShareSigma = flag.Float64("share-sigma", 0, "distance within which genomes share their fitness to keep the population diverse, 0 for no sharing")
+	// RNNOut is the file the state of the recurrent neural network is written to after every iteration
+	RNNOut = flag.String("rnn-out", "", "file to write the outputs and connections of the recurrent neural network to after every iteration, as json lines")
+	// DOTFile is the graphviz file the thresholded connections of the recurrent neural network are written to
+	DOTFile = flag.String("dot", "", "graphviz file to write the connections of the recurrent neural network above the threshold to")
+	// DOTDeviations is the number of standard deviations above the average connection of the graph threshold
+	DOTDeviations = flag.Float64("dot-deviations", 1, "threshold of the graph in standard deviations above the average connection")
+	// MutateScale evolves the scale of the random network layers with mutations in addition to the seed crossovers
+	MutateScale = flag.Bool("mutate-scale", false, "mutate the scale of the random network layers in addition to swapping their seeds")
+	// ShuffleFlag shuffles the training samples at the start of each generation
+	ShuffleFlag = flag.Bool("shuffle", false, "shuffle the training samples at the start of each generation")
+	// CPUProfile is the file the cpu profile is written to
+	CPUProfile = flag.String("cpuprofile", "", "file to write the cpu profile to")
+	// MemProfile is the file the memory profile is written to
+	MemProfile = flag.String("memprofile", "", "file to write the memory profile to")
+	// Elite is the number of best genomes carried into the next generation unmodified
+	Elite = flag.Int("elite", 0, "number of best genomes carried into the next generation unmodified")
+	// BatchSize is the number of samples the fitness is computed on each generation
+	BatchSize = flag.Int("batch", 0, "number of samples the fitness is computed on each generation, 0 for all of the samples")
+	// Timing prints the duration and throughput of training
+	Timing = flag.Bool("timing", false, "print the duration and throughput of training")
+	// Seed is the seed of the selected model, negative for the best known seed of the model
+	Seed = flag.Int("seed", -1, "seed of the selected model, negative for the best known seed")
+	// Size is the size of the recurrent neural network
+	Size = flag.Int("size", 8, "size of the recurrent neural network")
+	// MaskFlag is the hex lfsr polynomial used by the random number generator
+	MaskFlag = flag.String("mask", fmt.Sprintf("%x", LFSRMask), "hex lfsr polynomial used by the random number generator")
+	// LFSRCount is the number of polynomials the lfsr search checks
+	LFSRCount = flag.Int("lfsr-count", 0, "number of polynomials to check in the lfsr search, 0 for all")
 	// Real uses the real network
 	Real = flag.Bool("real", false, "real network")
 	// Random is a random neural network
@@ -24,8 +125,55 @@ var (
 	Complex = flag.Bool("complex", false, "complex network")
 	// RNN uses the recurrent neural network
 	RNN = flag.Bool("rnn", false, "recurrent neural network")
+	// Genomes is the population size of the models
+	// The inputs the stored weights apply to depend on the layer widths rather than the population size, so any positive size works
+	Genomes = flag.Int("genomes", NumGenomes, "population size of the models")
+	// Generations is the number of generations the models are trained for
+	Generations = flag.Int("generations", 128, "number of generations to train the models for")
 	// Search search for the best seed
 	Search = flag.Bool("search", false, "search for the best seed")
+	// AutoMode runs all of the model families and picks the best
+	AutoMode = flag.Bool("auto", false, "run all of the model families and pick the best")
+	// BestOf trains the real network over a number of seeds and saves the best
+	BestOf = flag.Int("best-of", 0, "train the real network over a number of seeds and save the best")
+	// SetWeight overrides a weight of the real network loaded from the file argument
+	SetWeight = flag.String("set-weight", "", "override layer,index,value of the real network loaded from the file argument")
+	// PermuteFeatures compares the quality of the real network under permuted input features
+	PermuteFeatures = flag.Bool("permute-features", false, "compare quality under permuted input features")
+	// Rerandomize is the number of generations between re-randomizing the random connections of the real network
+	Rerandomize = flag.Int("rerandomize", 0, "generations between re-randomizing the real network random connections")
+	// Verbose prints per generation statistics
+	Verbose = flag.Bool("verbose", false, "print per generation statistics of every model")
+	// Hard evaluates the real network without the random connections
+	Hard = flag.Bool("hard", false, "evaluate the real network without the random connections")
+	// Precision is the number of decimal places quality and fitness are printed with
+	Precision = flag.Int("precision", -1, "number of decimal places to print quality and fitness with")
+	// MaxEvaluations is the maximum number of fitness evaluations per model run
+	MaxEvaluations = flag.Int("max-evaluations", 0, "maximum number of fitness evaluations per model run")
+	// MixedTopology initializes the real network population with a mixture of hidden layer sizes
+	MixedTopology = flag.Bool("mixed-topology", false, "initialize the real network population with mixed hidden layer sizes")
+	// ConfusionFlag prints the confusion matrix after each model run
+	ConfusionFlag = flag.Bool("confusion", false, "print the confusion matrix after each model run")
+	// MetricsFlag prints the per class precision, recall, and F1 score after each model run
+	MetricsFlag = flag.Bool("metrics", false, "print the per class precision, recall, and F1 score after each model run")
+	// ECE prints the expected calibration error of the real network
+	ECE = flag.Bool("ece", false, "print the expected calibration error of the real network")
+	// Diversity is the csv file the real network population diversity is written to
+	Diversity = flag.String("diversity", "", "csv file to write the real network population diversity to")
+	// SearchOut is the file the search results are written to
+	SearchOut = flag.String("search-out", "", "csv file to write the search results to")
+	// Out is an alias of SearchOut
+	Out = flag.String("out", "", "csv file to write the search results to, an alias of -search-out")
+	// TestFrac is the fraction of the data set held out for testing
+	TestFrac = flag.Float64("testfrac", 0.2, "fraction of the data set held out for testing")
+	// DatasetName is the name of the data set to train on
+	DatasetName = flag.String("dataset", "iris", "name of the data set to train on")
+	// Classes is a comma separated list of the iris classes to train on
+	Classes = flag.String("classes", "", "comma separated list of iris classes to train on")
+	// InferFile is the file of a saved network that classifies feature vectors
+	InferFile = flag.String("infer", "", "classify the comma separated feature vector arguments, or the lines of stdin, with the network saved in the file, -shared, -random, or -complex select the network type")
+	// Replay retrains a model with the seed given as an argument and saves the champion
+	Replay = flag.String("replay", "", "retrain a model, real, shared, random, or complex, with the seed argument and save the champion")
 )
 
 const (
@@ -37,18 +185,95 @@ const (
 	NumGenomes = 256
 	// SearchIterations is the number of search iterations
 	SearchIterations = 256
-	// Size is the size of the recurrent neural network
-	Size = 8
+	// AutoSeeds is the number of seeds each family is trained with in auto mode
+	AutoSeeds = 4
 )
 
+// HiddenSizes are the hidden layer sizes of the mixed topology population
+var HiddenSizes = []int{2, 4, 8}
+
+// Source is a source of random numbers
+type Source interface {
+	// Float32 returns a random float32 between 0 and 1
+	Float32() float32
+	// Uint32 returns a random uint32
+	Uint32() uint32
+}
+
+// NewSource creates the source of random numbers used by a layer during inference from its seed
+var NewSource = func(seed Rand) Source {
+	return &seed
+}
+
+// LayerSeed derives the random seed of a layer of a genome by hashing the seed of the model run, the genome, and the layer
+// Every layer of every genome gets its own seed, which is unrelated to the seeds of the other layers and of the run
+func LayerSeed(seed, genome, layer int) Rand {
+	hash, buffer := fnv.New32a(), make([]byte, 8)
+	for _, value := range []int{seed, genome, layer} {
+		binary.LittleEndian.PutUint64(buffer, uint64(value))
+		hash.Write(buffer)
+	}
+	if sum := hash.Sum32(); sum != 0 {
+		return Rand(sum)
+	}
+	// the zero state is never left
+	return LFSRInit
+}
+
+// Mask is the lfsr polynomial used by Rand
+var Mask uint32 = LFSRMask
+
 // Rand is a random number generator
 type Rand uint32
 
+// Seed sets the state of the random number generator, a zero seed never changes state
+func (r *Rand) Seed(seed uint32) {
+	*r = Rand(seed)
+}
+
+// Clone returns an independent copy of the random number generator
+func (r *Rand) Clone() *Rand {
+	clone := *r
+	return &clone
+}
+
+// Int returns a non-negative random int
+func (r *Rand) Int() int {
+	return int(r.Uint32() >> 1)
+}
+
+// IntN returns a random int in [0, n) without modulo bias
+func (r *Rand) IntN(n int) int {
+	if n <= 0 {
+		panic(fmt.Errorf("invalid argument to IntN: %d", n))
+	}
+	max := uint32(n)
+	limit := math.MaxUint32 - math.MaxUint32%max
+	value := r.Uint32()
+	for value >= limit {
+		value = r.Uint32()
+	}
+	return int(value % max)
+}
+
+// Perm returns a random permutation of [0, n) generated with a Fisher-Yates shuffle
+func (r *Rand) Perm(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j := r.IntN(i + 1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm
+}
+
 // Float32 returns a random float32 between 0 and 1
 func (r *Rand) Float32() float32 {
 	lfsr := *r
 	if lfsr&1 == 1 {
-		lfsr = (lfsr >> 1) ^ LFSRMask
+		lfsr = (lfsr >> 1) ^ Rand(Mask)
 	} else {
 		lfsr = lfsr >> 1
 	}
@@ -60,7 +285,7 @@ func (r *Rand) Float32() float32 {
 func (r *Rand) Uint32() uint32 {
 	lfsr := *r
 	if lfsr&1 == 1 {
-		lfsr = (lfsr >> 1) ^ LFSRMask
+		lfsr = (lfsr >> 1) ^ Rand(Mask)
 	} else {
 		lfsr = lfsr >> 1
 	}
@@ -68,158 +293,447 @@ func (r *Rand) Uint32() uint32 {
 	return uint32(lfsr)
 }
 
+// Save saves a network to a json file if the file has a .json extension, otherwise a gob file
+func Save(file string, network interface{}) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return encode(out, file, network)
+}
+
+// SaveAtomic saves a network like Save, writing a temporary file that is renamed to file
+// so that file is never left partially written
+func SaveAtomic(file string, network interface{}) error {
+	out, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = encode(out, file, network)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Rename(out.Name(), file)
+}
+
+// encode encodes a network as json if the file has a .json extension, otherwise as gob
+func encode(out io.Writer, file string, network interface{}) error {
+	if filepath.Ext(file) == ".json" {
+		return json.NewEncoder(out).Encode(network)
+	}
+	return gob.NewEncoder(out).Encode(network)
+}
+
+// Load loads a network from a json file if the file has a .json extension, otherwise a gob file
+func Load(file string, network interface{}) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if filepath.Ext(file) == ".json" {
+		err = json.NewDecoder(in).Decode(network)
+	} else {
+		err = gob.NewDecoder(in).Decode(network)
+	}
+	if err != nil {
+		return err
+	}
+	if n, ok := network.(interface{ defaults() }); ok {
+		n.defaults()
+	}
+	return nil
+}
+
+// Output is where the models print to
+var Output io.Writer = os.Stdout
+
+// Println prints the values to Output formatting floats with the configured precision
+func Println(values ...interface{}) {
+	if *Precision >= 0 {
+		for i, value := range values {
+			switch v := value.(type) {
+			case float32:
+				values[i] = fmt.Sprintf("%.*f", *Precision, v)
+			case float64:
+				values[i] = fmt.Sprintf("%.*f", *Precision, v)
+			}
+		}
+	}
+	fmt.Fprintln(Output, values...)
+}
+
 func main() {
 	flag.Parse()
 
-	type Result struct {
-		Seed    int
-		Quality float64
+	if *Quiet {
+		Output = io.Discard
 	}
-	process := func(model func(seed int) float64) {
-		results := make(chan Result, runtime.NumCPU())
-		routine := func(seed int) {
-			results <- Result{
-				Seed:    seed,
-				Quality: model(seed * NumGenomes),
-			}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// a second interrupt terminates immediately
+		stop()
+	}()
+	Context = ctx
+
+	if *CPUProfile != "" {
+		out, err := os.Create(*CPUProfile)
+		if err != nil {
+			panic(err)
+		}
+		defer out.Close()
+		err = pprof.StartCPUProfile(out)
+		if err != nil {
+			panic(err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if *TraceFile != "" {
+		out, err := os.Create(*TraceFile)
+		if err != nil {
+			panic(err)
 		}
-		min, seed, count, j, flight := 1.0, 0, 0, 0, 0
-		for i := 0; i < runtime.NumCPU() && j < SearchIterations; i++ {
-			go routine(j)
-			j++
-			flight++
-		}
-		for j < SearchIterations {
-			result := <-results
-			flight--
-			if result.Quality < min {
-				min, seed = result.Quality, result.Seed
+		defer out.Close()
+		writer := bufio.NewWriter(out)
+		defer writer.Flush()
+		TraceSources(writer)
+	}
+	if *MemProfile != "" {
+		defer func() {
+			out, err := os.Create(*MemProfile)
+			if err != nil {
+				panic(err)
 			}
-			if result.Quality < .1 {
-				count++
+			defer out.Close()
+			runtime.GC()
+			err = pprof.WriteHeapProfile(out)
+			if err != nil {
+				panic(err)
 			}
+		}()
+	}
 
-			go routine(j)
-			j++
-			flight++
-		}
-		for i := 0; i < flight; i++ {
-			result := <-results
-			if result.Quality < min {
-				min, seed = result.Quality, result.Seed
+	mask, err := strconv.ParseUint(*MaskFlag, 16, 32)
+	if err != nil {
+		panic(err)
+	}
+	if mask == 0 {
+		panic(fmt.Errorf("mask must be nonzero"))
+	}
+	Mask = uint32(mask)
+
+	if *Genomes <= 0 {
+		panic(fmt.Errorf("genomes must be positive: %d", *Genomes))
+	}
+	if *LayersSpec != "" {
+		var sizes []int
+		for _, size := range strings.Split(*LayersSpec, ",") {
+			s, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				panic(err)
 			}
-			if result.Quality < .1 {
-				count++
+			if s <= 0 {
+				panic(fmt.Errorf("layer sizes must be positive: %s", *LayersSpec))
 			}
+			sizes = append(sizes, s)
 		}
-		fmt.Println(min, seed, count)
+		if len(sizes) < 2 {
+			panic(fmt.Errorf("layers must have at least an input and an output size: %s", *LayersSpec))
+		}
+		LayerSizes = sizes
+	}
+	if *Dropout < 0 || *Dropout > 1 {
+		panic(fmt.Errorf("dropout must be between 0 and 1: %f", *Dropout))
+	}
+	if *L2 < 0 {
+		panic(fmt.Errorf("l2 must not be negative: %f", *L2))
+	}
+	if *TournamentSize < 1 {
+		panic(fmt.Errorf("tournament size must be positive: %d", *TournamentSize))
+	}
+	selection, err := NewSelection(*SelectionName, *TournamentSize)
+	if err != nil {
+		panic(err)
+	}
+	ParentSelection = selection
+	if *Elite < 0 || *Elite > *Genomes {
+		panic(fmt.Errorf("elite must be between 0 and the number of genomes: %d", *Elite))
+	}
+	if *Generations <= 0 {
+		panic(fmt.Errorf("generations must be positive: %d", *Generations))
+	}
+	if _, ok := Activations[*ActivationName]; !ok {
+		panic(fmt.Errorf("unknown activation: %s", *ActivationName))
+	}
+	loss, ok := Losses[*LossName]
+	if !ok {
+		panic(fmt.Errorf("unknown loss: %s", *LossName))
+	}
+	FitnessLoss = loss
+	if *LossName != "rmse" {
+		ComplexLoss = loss
+	}
+	if *MutationStrength < 0 {
+		panic(fmt.Errorf("mutation strength must not be negative: %f", *MutationStrength))
+	}
+	decay, ok := Decays[*MutationDecayName]
+	if !ok {
+		panic(fmt.Errorf("unknown mutation decay: %s", *MutationDecayName))
+	}
+	MutationDecay = decay
+	encoding, ok := Encodings[*EncodingName]
+	if !ok {
+		panic(fmt.Errorf("unknown encoding: %s", *EncodingName))
+	}
+	ComplexEncoding = encoding
+	decision, ok := Decisions[*DecisionName]
+	if !ok {
+		panic(fmt.Errorf("unknown decision: %s", *DecisionName))
+	}
+	ComplexDecision = decision
+	if *Pool <= 0 || *Pool&(*Pool-1) != 0 {
+		panic(fmt.Errorf("pool must be a power of two: %d", *Pool))
+	}
+	if *ShareSigma < 0 {
+		panic(fmt.Errorf("share sigma must not be negative: %f", *ShareSigma))
+	}
+	if *CheckpointEvery < 0 {
+		panic(fmt.Errorf("checkpoint every must not be negative: %d", *CheckpointEvery))
+	}
+	// the seeds of a search run concurrently, so they would overwrite each other's files
+	if *Search && (*CheckpointEvery > 0 || *PopulationFile != "" || *Diversity != "") {
+		panic(fmt.Errorf("checkpoint-every, save-population, and diversity can't be used with search"))
+	}
+	if *TestFrac < 0 || *TestFrac >= 1 {
+		panic(fmt.Errorf("test fraction must be at least 0 and less than 1: %f", *TestFrac))
+	}
+	if *Size < 2 {
+		panic(fmt.Errorf("size must be at least 2: %d", *Size))
 	}
 
-	if *LFSR {
-		// https://en.wikipedia.org/wiki/Linear-feedback_shift_register
-		// https://users.ece.cmu.edu/~koopman/lfsr/index.html
-		count, polynomial := 0, uint32(0x80000000)
-		for polynomial != 0 {
-			lfsr, period := uint32(1), uint32(0)
-			for {
-				lfsr = (lfsr >> 1) ^ (-(lfsr & 1) & polynomial)
-				period++
-				if lfsr == 1 {
-					break
-				}
-			}
-			fmt.Printf("%v period=%v\n", count, period)
-			if period == math.MaxUint32 {
-				fmt.Printf("%x\n", polynomial)
-				return
+	if *Classes != "" {
+		var classes []int
+		for _, class := range strings.Split(*Classes, ",") {
+			c, err := strconv.Atoi(class)
+			if err != nil {
+				panic(err)
 			}
+			classes = append(classes, c)
+		}
+		SelectedClasses = classes
+	}
+
+	// seed returns the seed of the selected model given its best known seed
+	seed := func(best int) int {
+		if *Seed >= 0 {
+			best = *Seed
+		}
+		Println("seed", best)
+		return best * NumGenomes
+	}
+
+	if *LFSR {
+		start, err := strconv.ParseUint(*LFSRStart, 16, 32)
+		if err != nil {
+			panic(err)
+		}
+		count := 0
+		polynomial, _, found := FindMaximalLFSR(uint32(start), uint32(*LFSRCount), func(polynomial, period uint32) {
+			fmt.Fprintf(Output, "%v %x period=%v\n", count, polynomial, period)
 			count++
-			polynomial++
+		})
+		if found {
+			fmt.Fprintf(Output, "%x\n", polynomial)
 		}
 		return
+	} else if *InferFile != "" {
+		var network interface{ Validate() error }
+		var err error
+		switch {
+		case *Shared:
+			var n SharedNetwork
+			err = Load(*InferFile, &n)
+			network = n
+		case *Random:
+			var n RandomNetwork
+			err = Load(*InferFile, &n)
+			network = n
+		case *Complex:
+			var n ComplexNetwork
+			err = Load(*InferFile, &n)
+			network = n
+		default:
+			var n RealNetwork
+			err = Load(*InferFile, &n)
+			network = n
+		}
+		if err != nil {
+			panic(err)
+		}
+		err = network.Validate()
+		if err != nil {
+			panic(err)
+		}
+		classifier, err := NewClassifier(network)
+		if err != nil {
+			panic(err)
+		}
+		train, _, normalization := loadData()
+		var in io.Reader = os.Stdin
+		if flag.NArg() > 0 {
+			in = strings.NewReader(strings.Join(flag.Args(), "\n"))
+		}
+		err = Infer(classifier, train.Labels, normalization, in)
+		if err != nil {
+			panic(err)
+		}
+		return
+	} else if *Replay != "" {
+		seed, err := strconv.Atoi(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		family, err := LookupFamily(*Replay)
+		if err != nil {
+			panic(err)
+		}
+		_, quality, matrix, err := ReplayChampion(family, seed, fmt.Sprintf("%s_%d.gob", *Replay, seed))
+		if err != nil {
+			panic(err)
+		}
+		Println("accuracy", 1-quality)
+		fmt.Fprint(Output, matrix)
+		return
+	} else if *AutoMode {
+		family, network, quality := Auto(Families, AutoSeeds)
+		Println(family, quality)
+		fmt.Fprintln(Output, network)
+		return
+	} else if *SetWeight != "" {
+		parts := strings.Split(*SetWeight, ",")
+		if len(parts) != 3 {
+			panic(fmt.Errorf("invalid weight %s", *SetWeight))
+		}
+		layer, err := strconv.Atoi(parts[0])
+		if err != nil {
+			panic(err)
+		}
+		index, err := strconv.Atoi(parts[1])
+		if err != nil {
+			panic(err)
+		}
+		value, err := strconv.ParseFloat(parts[2], 32)
+		if err != nil {
+			panic(err)
+		}
+		var network RealNetwork
+		err = Load(flag.Arg(0), &network)
+		if err != nil {
+			panic(err)
+		}
+		err = network.Validate()
+		if err != nil {
+			panic(err)
+		}
+		_, test := LoadData()
+		Println("before", Evaluate(network, test.Samples))
+		err = network.SetWeight(layer, index, float32(value))
+		if err != nil {
+			panic(err)
+		}
+		Println("after", Evaluate(network, test.Samples))
+		return
+	} else if *BestOf > 0 {
+		start := 0
+		if flag.NArg() > 0 {
+			var err error
+			start, err = strconv.Atoi(flag.Arg(0))
+			if err != nil {
+				panic(err)
+			}
+		}
+		network, quality := BestOverSeeds(start, *BestOf)
+		Println("best", quality)
+		err := Save("real_best.gob", network)
+		if err != nil {
+			panic(err)
+		}
+		return
+	} else if *PermuteFeatures {
+		_, test := LoadData()
+		network, _ := RealNetworkModelBest(seed(185))
+		rnd, perm := Rand(LFSRInit), Identity(test.Features)
+		Println("identity", perm, Evaluate(network, test.Samples))
+		perm = rnd.Perm(len(perm))
+		Println("permuted", perm, EvaluatePermuted(network, test.Samples, perm))
+		return
 	} else if *Real {
 		if *Search {
-			process(RealNetworkModel)
+			process(ctx, RealModel{})
 		} else {
-			// 0.02 135 14
-			RealNetworkModel(135 * NumGenomes)
+			// 0.03333333333333333 185 9
+			RealNetworkModel(seed(185))
 		}
 		return
 	} else if *Random {
 		if *Search {
-			process(RandomNetworkModel)
+			process(ctx, RandomModel{})
 		} else {
-			// 0.04666666666666667 1391 32
-			RandomNetworkModel(1391 * NumGenomes)
+			// 0.26666666666666666 135 0
+			RandomNetworkModel(seed(135))
 		}
 		return
 	} else if *Complex {
 		if *Search {
-			process(ComplexNetworkModel)
+			process(ctx, ComplexModel{})
 		} else {
-			// 0.05333333333333334 186 1
-			ComplexNetworkModel(186 * NumGenomes)
+			// 0.03333333333333333 19 2
+			ComplexNetworkModel(seed(19))
 		}
 		return
 	} else if *Shared {
 		if *Search {
-			// 0.06 152 1
-			process(SharedNetworkModel)
+			process(ctx, SharedModel{})
 		} else {
-			SharedNetworkModel(152 * NumGenomes)
+			// 0.03333333333333333 35 1
+			SharedNetworkModel(seed(35))
 		}
 		return
 	} else if *RNN {
-		g := Rand(LFSRInit)
-		waves, inputs, outputs, connections, factor :=
-			make([]float32, 2), make([]float32, Size), make([]float32, Size), make([][]float32, Size), float32(math.Sqrt(2/float64(Size)))
-		for i := range connections {
-			connections[i] = make([]float32, Size)
-			for j := range connections[i] {
-				connections[i][j] = 1
-			}
-		}
-		for i := 0; i < 1024; i++ {
-			rnd := Rand(LFSRInit + 3*Size*Size)
-			for j := 0; j < Size; j++ {
-				sum := (2*rnd.Float32() - 1) * factor
-				for _, wave := range waves {
-					sum += (2*rnd.Float32() - 1) * factor * wave
-				}
-				for k := 0; k < Size; k++ {
-					if weight := rnd.Float32(); k == j {
-						sum += (2*weight - 1) * factor * inputs[k]
-					} else if g.Float32() > 1/float32(connections[j][k]) {
-						sum += (2*weight - 1) * factor * inputs[k]
-					}
+		if *Search {
+			process(ctx, RNNModelType{})
+		} else {
+			var snapshots io.Writer
+			if *RNNOut != "" {
+				out, err := os.Create(*RNNOut)
+				if err != nil {
+					panic(err)
 				}
-				e := float32(math.Exp(float64(sum)))
-				outputs[j] = e / (e + 1)
+				defer out.Close()
+				writer := bufio.NewWriter(out)
+				defer writer.Flush()
+				snapshots = writer
 			}
-			for j, a := range outputs {
-				for k, b := range outputs {
-					if j == k {
-						continue
-					}
-					if a > .5 && b > .5 {
-						connections[j][k]++
-					} else if connections[j][k] > 1 {
-						connections[j][k]--
-					}
+			connections, _ := RNNModelSnapshots(seed(0), *Size, snapshots)
+			if *DOTFile != "" {
+				out, err := os.Create(*DOTFile)
+				if err != nil {
+					panic(err)
+				}
+				defer out.Close()
+				average, standardDeviation := ConnectionStats(connections)
+				err = WriteDOT(out, Adjacency(connections, average+*DOTDeviations*standardDeviation))
+				if err != nil {
+					panic(err)
 				}
-			}
-			copy(inputs, outputs)
-			fmt.Println(i, outputs)
-			fmt.Println(connections)
-			switch true {
-			case waves[0] == 0 && waves[1] == 0:
-				waves[0], waves[1] = 1, 0
-			case waves[0] == 1 && waves[1] == 0:
-				waves[0], waves[1] = 0, 1
-			case waves[0] == 0 && waves[1] == 1:
-				waves[0], waves[1] = 1, 1
-			case waves[0] == 1 && waves[1] == 1:
-				waves[0], waves[1] = 0, 0
 			}
 		}
 	}
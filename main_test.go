@@ -0,0 +1,197 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// discard returns a writer that discards what the models print, restoring Output when the test ends
+func discard(t *testing.T) io.Writer {
+	t.Cleanup(func() {
+		Output = os.Stdout
+	})
+	return io.Discard
+}
+
+func TestRandSeed(t *testing.T) {
+	rnd := Rand(1)
+	rnd.Uint32()
+	rnd.Seed(LFSRInit)
+	fresh := Rand(LFSRInit)
+	for i := 0; i < 8; i++ {
+		if a, b := rnd.Uint32(), fresh.Uint32(); a != b {
+			t.Fatalf("reseeded generator returned %d, expected %d", a, b)
+		}
+	}
+}
+
+func TestRandClone(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	clone := rnd.Clone()
+	clone.Uint32()
+	if rnd != LFSRInit {
+		t.Fatal("advancing the clone advanced the original")
+	}
+	if a, b := rnd.Uint32(), *clone; Rand(a) != b {
+		t.Fatalf("original advanced to %d, expected the state %d of the clone", a, b)
+	}
+}
+
+func TestRandIntN(t *testing.T) {
+	rnd, counts := Rand(LFSRInit), make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		value := rnd.IntN(3)
+		if value < 0 || value >= 3 {
+			t.Fatalf("IntN(3) returned %d", value)
+		}
+		counts[value]++
+	}
+	for value, count := range counts {
+		if count < 900 || count > 1100 {
+			t.Errorf("IntN(3) returned %d %d times out of 3000, expected about 1000", value, count)
+		}
+	}
+	if value := rnd.Int(); value < 0 {
+		t.Errorf("Int returned %d, expected a non-negative int", value)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("IntN(0) didn't panic")
+		}
+	}()
+	rnd.IntN(0)
+}
+
+func TestRandPerm(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	perm := rnd.Perm(10)
+	sorted := append([]int(nil), perm...)
+	sort.Ints(sorted)
+	if !reflect.DeepEqual(sorted, Identity(10)) {
+		t.Fatalf("%v is not a permutation of [0, 10)", perm)
+	}
+	if reflect.DeepEqual(perm, Identity(10)) {
+		t.Fatal("permutation is the identity")
+	}
+	again := Rand(LFSRInit)
+	if !reflect.DeepEqual(perm, again.Perm(10)) {
+		t.Fatal("permutation is not reproducible")
+	}
+}
+
+func TestMask(t *testing.T) {
+	defer func(mask uint32) {
+		Mask = mask
+	}(Mask)
+	a := Rand(LFSRInit)
+	a.Uint32()
+	Mask = 0xB4BCD35C
+	b := Rand(LFSRInit)
+	b.Uint32()
+	if a == b {
+		t.Fatal("changing the mask didn't change the sequence")
+	}
+	if expected := Rand(LFSRInit>>1) ^ 0xB4BCD35C; b != expected {
+		t.Fatalf("state %x, expected %x", uint32(b), uint32(expected))
+	}
+}
+
+// countingSource counts the random numbers drawn from it
+type countingSource struct {
+	Source
+	count *int
+}
+
+func (c countingSource) Float32() float32 {
+	*c.count++
+	return c.Source.Float32()
+}
+
+func (c countingSource) Uint32() uint32 {
+	*c.count++
+	return c.Source.Uint32()
+}
+
+func TestNewSource(t *testing.T) {
+	defer func(newSource func(seed Rand) Source) {
+		NewSource = newSource
+	}(NewSource)
+	outputs := make([]float32, 3)
+	goldenReal().Inference(testInputs, outputs)
+	count, newSource := 0, NewSource
+	NewSource = func(seed Rand) Source {
+		return countingSource{Source: newSource(seed), count: &count}
+	}
+	swapped := make([]float32, 3)
+	goldenReal().Inference(testInputs, swapped)
+	if !reflect.DeepEqual(outputs, swapped) {
+		t.Fatalf("outputs %v with the wrapped source, expected %v", swapped, outputs)
+	}
+	// a draw for the index of each neuron and a draw for each of its other inputs
+	if count != 4*4+3*4 {
+		t.Fatalf("%d random numbers drawn, expected %d", count, 4*4+3*4)
+	}
+}
+
+func TestLayerSeed(t *testing.T) {
+	seeds := make(map[Rand]bool)
+	for seed := 0; seed < 4; seed++ {
+		for genome := 0; genome < 16; genome++ {
+			for layer := 1; layer < 4; layer++ {
+				rnd := LayerSeed(seed*NumGenomes, genome, layer)
+				if rnd == 0 {
+					t.Fatal("layer seed is the zero state")
+				}
+				if rnd != LayerSeed(seed*NumGenomes, genome, layer) {
+					t.Fatal("layer seed is not reproducible")
+				}
+				seeds[rnd] = true
+			}
+		}
+	}
+	if len(seeds) != 4*16*3 {
+		t.Fatalf("%d distinct layer seeds, expected %d", len(seeds), 4*16*3)
+	}
+}
+
+func TestPrintlnPrecision(t *testing.T) {
+	defer func(precision int) {
+		*Precision = precision
+	}(*Precision)
+	var out bytes.Buffer
+	discard(t)
+	Output = &out
+	Println("quality", float32(.123456), 1/3.0, 7)
+	*Precision = 2
+	Println("quality", float32(.123456), 1/3.0, 7)
+	if expected := "quality 0.123456 0.3333333333333333 7\nquality 0.12 0.33 7\n"; out.String() != expected {
+		t.Fatalf("printed %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, network := range []interface{}{goldenReal(), goldenShared(), goldenRandom(), goldenComplex()} {
+		for _, name := range []string{"network.gob", "network.json"} {
+			file := filepath.Join(t.TempDir(), name)
+			if err := Save(file, network); err != nil {
+				t.Fatal(err)
+			}
+			loaded := reflect.New(reflect.TypeOf(network))
+			if err := Load(file, loaded.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(loaded.Elem().Interface(), network) {
+				t.Errorf("%T loaded from %s differs from the saved network", network, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "fmt"
+
+// Model is a model that can be trained
+type Model interface {
+	// Train trains the model with a seed and returns the quality
+	Train(seed int) (quality float64)
+}
+
+// ModelFunc is a function that is a model
+type ModelFunc func(seed int) float64
+
+// Train trains the model with a seed and returns the quality
+func (m ModelFunc) Train(seed int) float64 {
+	return m(seed)
+}
+
+// Models are the registered models
+var Models = map[string]Model{
+	"real":    RealModel{},
+	"random":  RandomModel{},
+	"shared":  SharedModel{},
+	"complex": ComplexModel{},
+	"rnn":     RNNModelType{},
+}
+
+// LayerSizes are the sizes of the layers of the networks from the inputs to the outputs, nil for the default architecture
+var LayerSizes []int
+
+// validateActivation checks that the activation of layer i is known, the empty name is the sigmoid
+func validateActivation(i int, name string) error {
+	if _, ok := Activations[name]; name != "" && !ok {
+		return fmt.Errorf("layer %d has unknown activation %s", i, name)
+	}
+	return nil
+}
+
+// validateColumns checks that layer i has a positive number of columns that matches the rows of the previous layer
+func validateColumns(i, columns, previous int) error {
+	if columns <= 0 {
+		return fmt.Errorf("layer %d has %d columns, expected a positive number", i, columns)
+	}
+	if i > 0 && columns != previous {
+		return fmt.Errorf("layer %d has %d columns, expected the %d rows of layer %d", i, columns, previous, i-1)
+	}
+	return nil
+}
+
+// validateRand checks that the random seed of layer i is not the zero state, which the lfsr never leaves
+func validateRand(i int, rnd Rand) error {
+	if rnd == 0 {
+		return fmt.Errorf("layer %d has a zero random seed", i)
+	}
+	return nil
+}
+
+// Architecture returns the sizes of the layers of a network from the inputs to the outputs
+// The default architecture has a single hidden layer of size 4
+func Architecture(features, classes int) []int {
+	if LayerSizes == nil {
+		return []int{features, 4, classes}
+	}
+	if LayerSizes[0] != features || LayerSizes[len(LayerSizes)-1] != classes {
+		panic(fmt.Errorf("layers %v must start with %d features and end with %d classes", LayerSizes, features, classes))
+	}
+	sizes := make([]int, len(LayerSizes))
+	copy(sizes, LayerSizes)
+	return sizes
+}
+
+// Register registers a model
+func Register(name string, model Model) {
+	Models[name] = model
+}
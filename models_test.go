@@ -0,0 +1,286 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// smallModels trains the models with a small population for a few generations until the test ends
+func smallModels(t *testing.T) {
+	Output = discard(t)
+	generations, genomes := *Generations, *Genomes
+	t.Cleanup(func() {
+		*Generations, *Genomes = generations, genomes
+	})
+	*Generations, *Genomes = 8, 32
+}
+
+// histories are the models that return the best fitness of each generation by family
+var histories = map[string]func(seed int) ([]float32, float64){
+	"real":    RealNetworkModelHistory,
+	"shared":  SharedNetworkModelHistory,
+	"random":  RandomNetworkModelHistory,
+	"complex": ComplexNetworkModelHistory,
+}
+
+// golden is the final best fitness and quality of a model family trained with a seed
+type golden struct {
+	family  string
+	seed    int
+	fitness float32
+	quality float64
+}
+
+func TestRealNetworkModel(t *testing.T) {
+	smallModels(t)
+	for _, seed := range []int{0, 185} {
+		quality := RealNetworkModel(seed * NumGenomes)
+		if quality < 0 || quality > 1 {
+			t.Errorf("seed %d has a quality of %f, expected a miss rate in [0, 1]", seed, quality)
+		}
+		if again := RealNetworkModel(seed * NumGenomes); again != quality {
+			t.Errorf("seed %d has a quality of %f and then %f, expected the same quality", seed, quality, again)
+		}
+	}
+}
+
+func TestSharedNetworkModel(t *testing.T) {
+	smallModels(t)
+	for _, seed := range []int{0, 35} {
+		quality := SharedNetworkModel(seed * NumGenomes)
+		if quality < 0 || quality > 1 {
+			t.Errorf("seed %d has a quality of %f, expected a miss rate in [0, 1]", seed, quality)
+		}
+		if again := SharedNetworkModel(seed * NumGenomes); again != quality {
+			t.Errorf("seed %d has a quality of %f and then %f, expected the same quality", seed, quality, again)
+		}
+	}
+}
+
+func TestModelsGoldenDefaults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("trains the models with the default population and generations")
+	}
+	Output = discard(t)
+	// the best seeds of the search, which main trains by default
+	for _, g := range []golden{
+		{"real", 185, 0.31722975, 0.03333333333333333},
+		{"shared", 35, 0.21489012, 0.03333333333333333},
+		{"random", 135, 0.45465475, 0.26666666666666666},
+		{"complex", 19, 0.2036865, 0.03333333333333333},
+	} {
+		history, quality := histories[g.family](g.seed * NumGenomes)
+		if fitness := history[len(history)-1]; fitness != g.fitness || quality != g.quality {
+			t.Errorf("%s seed %d has a fitness of %v and quality of %v, expected %v and %v",
+				g.family, g.seed, fitness, quality, g.fitness, g.quality)
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	smallModels(t)
+	for name, model := range map[string]func(seed int) float64{
+		"real":    RealNetworkModel,
+		"shared":  SharedNetworkModel,
+		"random":  RandomNetworkModel,
+		"complex": ComplexNetworkModel,
+	} {
+		// the registered model must train exactly like the function it wraps
+		for seed := 0; seed < 3; seed++ {
+			quality := model(seed * NumGenomes)
+			if trained := Models[name].Train(seed * NumGenomes); trained != quality {
+				t.Errorf("%s model trained seed %d with a quality of %f, expected %f", name, seed, trained, quality)
+			}
+			if quality < 0 || quality > 1 {
+				t.Errorf("%s model has a quality of %f for seed %d, expected a miss rate", name, quality, seed)
+			}
+		}
+	}
+	Register("constant", ModelFunc(func(seed int) float64 {
+		return float64(seed) / 10
+	}))
+	defer delete(Models, "constant")
+	if quality := Models["constant"].Train(3); quality != .3 {
+		t.Errorf("registered model has a quality of %f, expected .3", quality)
+	}
+}
+
+func TestModelsDeterministic(t *testing.T) {
+	smallModels(t)
+	for _, family := range Families {
+		network, quality := family.Model(NumGenomes)
+		again, againQuality := family.Model(NumGenomes)
+		if !reflect.DeepEqual(network, again) || quality != againQuality {
+			t.Errorf("%s model is not reproducible for a fixed seed", family.Name)
+		}
+	}
+}
+
+func TestModelsBest(t *testing.T) {
+	smallModels(t)
+	_, test := LoadData()
+	for _, family := range Families {
+		network, quality := family.Model(NumGenomes)
+		classifier, err := NewClassifier(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		misses := 0
+		for _, sample := range test.Samples {
+			if index, _ := classifier.Inference(sample.Inputs); index != sample.Label {
+				misses++
+			}
+		}
+		if reproduced := float64(misses) / float64(len(test.Samples)); reproduced != quality {
+			t.Errorf("%s champion has a quality of %f, expected the reported %f", family.Name, reproduced, quality)
+		}
+	}
+}
+
+func TestModelsHistory(t *testing.T) {
+	smallModels(t)
+	for name, model := range histories {
+		history, _ := model(NumGenomes)
+		if len(history) != *Generations {
+			t.Fatalf("%s history has %d generations, expected %d", name, len(history), *Generations)
+		}
+		// the survivors keep their fitness, so the best fitness never increases
+		for i := 1; i < len(history); i++ {
+			if history[i] > history[i-1] {
+				t.Errorf("%s best fitness increased from %f to %f at generation %d", name, history[i-1], history[i], i)
+			}
+		}
+	}
+}
+
+func TestModelsLayers(t *testing.T) {
+	smallModels(t)
+	defer func() {
+		LayerSizes = nil
+	}()
+	LayerSizes = []int{4, 5, 6, 3}
+	for _, family := range Families {
+		network, quality := family.Model(NumGenomes)
+		if err := network.(interface{ Validate() error }).Validate(); err != nil {
+			t.Fatalf("%s: %v", family.Name, err)
+		}
+		if layers := reflect.ValueOf(network).Len(); layers != 3 {
+			t.Errorf("%s network has %d layers, expected 3", family.Name, layers)
+		}
+		if quality < 0 || quality > 1 {
+			t.Errorf("%s model has a quality of %f, expected a miss rate", family.Name, quality)
+		}
+	}
+}
+
+func TestModelsPopulationSize(t *testing.T) {
+	smallModels(t)
+	*Genomes = 5
+	for _, family := range Families {
+		if _, quality := family.Model(NumGenomes); quality < 0 || quality > 1 {
+			t.Errorf("%s model has a quality of %f with 5 genomes, expected a miss rate", family.Name, quality)
+		}
+	}
+}
+
+func TestMixedTopology(t *testing.T) {
+	smallModels(t)
+	defer func(mixed bool, file string) {
+		*MixedTopology, *PopulationFile = mixed, file
+	}(*MixedTopology, *PopulationFile)
+	*MixedTopology, *PopulationFile = true, filepath.Join(t.TempDir(), "population.gob")
+	RealNetworkModel(NumGenomes)
+	// the evolution runs with the mixture, and after a single generation the population is still the initial mixture
+	*Generations = 1
+	RealNetworkModel(NumGenomes)
+	genomes, err := LoadPopulation(*PopulationFile, RealNetwork{}, *Genomes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[int]bool)
+	for _, genome := range genomes {
+		sizes[len(genome.Network.(RealNetwork)[0].Biases)] = true
+	}
+	if len(sizes) != len(HiddenSizes) {
+		t.Fatalf("population has hidden layer sizes %v, expected each of %v", sizes, HiddenSizes)
+	}
+}
+
+func TestCachedModel(t *testing.T) {
+	smallModels(t)
+	defer func(cache bool) {
+		*Cache = cache
+	}(*Cache)
+	history, quality := RealNetworkModelHistory(NumGenomes)
+	*Cache = true
+	cachedHistory, cachedQuality := RealNetworkModelHistory(NumGenomes)
+	if !reflect.DeepEqual(history, cachedHistory) || quality != cachedQuality {
+		t.Fatal("training with cached random weights changed the model")
+	}
+}
+
+func TestRandomCrossover(t *testing.T) {
+	smallModels(t)
+	defer func(file string) {
+		*PopulationFile = file
+	}(*PopulationFile)
+	*PopulationFile = filepath.Join(t.TempDir(), "population.gob")
+	RandomNetworkModel(NumGenomes)
+	genomes, err := LoadPopulation(*PopulationFile, RandomNetwork{}, *Genomes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the children combine the layers of different parents from the initial population
+	owners := make(map[Rand]int)
+	for i := 0; i < *Genomes; i++ {
+		owners[LayerSeed(NumGenomes, i, 1)] = i
+	}
+	exchanged := false
+	for _, genome := range genomes {
+		network := genome.Network.(RandomNetwork)
+		first, ok := owners[network[0].Rand]
+		if !ok {
+			t.Fatalf("layer seed %d is not the first layer seed of an initial genome", network[0].Rand)
+		}
+		exchanged = exchanged || network[1].Rand != LayerSeed(NumGenomes, first, 2)
+	}
+	if !exchanged {
+		t.Fatal("every genome has the layers of a single initial genome, expected the crossover to exchange layers")
+	}
+}
+
+func TestRealNetworkModelSnapshots(t *testing.T) {
+	smallModels(t)
+	snapshots, quality := RealNetworkModelSnapshots(NumGenomes, 4)
+	if len(snapshots) != 2 {
+		t.Fatalf("%d snapshots, expected 2", len(snapshots))
+	}
+	network, best := RealNetworkModelBest(NumGenomes)
+	if !reflect.DeepEqual(snapshots[1], network) || quality != best {
+		t.Fatal("last snapshot differs from the best network")
+	}
+}
+
+func TestBestOverSeeds(t *testing.T) {
+	smallModels(t)
+	network, quality := BestOverSeeds(0, 3)
+	best := math.MaxFloat64
+	for seed := 0; seed < 3; seed++ {
+		if _, q := RealNetworkModelBest(seed * NumGenomes); q < best {
+			best = q
+		}
+	}
+	if quality != best {
+		t.Fatalf("best quality over the seeds is %f, expected %f", quality, best)
+	}
+	_, test := LoadData()
+	if reproduced := Evaluate(network, test.Samples); reproduced != quality {
+		t.Fatalf("best network has a quality of %f, expected %f", reproduced, quality)
+	}
+}
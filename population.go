@@ -0,0 +1,79 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SavePopulation atomically saves the networks of the genomes to a file, see Save
+func SavePopulation(file string, genomes []Genome) error {
+	if len(genomes) == 0 {
+		return fmt.Errorf("population is empty")
+	}
+	networks := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(genomes[0].Network)), len(genomes), len(genomes))
+	for i, genome := range genomes {
+		networks.Index(i).Set(reflect.ValueOf(genome.Network))
+	}
+	return SaveAtomic(file, networks.Interface())
+}
+
+// LoadPopulation loads a population of size networks of the same type as network from a file saved with SavePopulation
+func LoadPopulation(file string, network interface{}, size int) ([]Genome, error) {
+	networks := reflect.New(reflect.SliceOf(reflect.TypeOf(network)))
+	err := Load(file, networks.Interface())
+	if err != nil {
+		return nil, err
+	}
+	loaded := networks.Elem()
+	if loaded.Len() != size {
+		return nil, fmt.Errorf("population in %s has %d genomes, expected %d", file, loaded.Len(), size)
+	}
+	genomes := make([]Genome, size)
+	for i := range genomes {
+		genomes[i].Network = loaded.Index(i).Interface()
+		if n, ok := genomes[i].Network.(interface{ defaults() }); ok {
+			n.defaults()
+		}
+		if validator, ok := genomes[i].Network.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return nil, fmt.Errorf("genome %d in %s: %w", i, file, err)
+			}
+		}
+	}
+	return genomes, nil
+}
+
+// Resume replaces the initial genomes with the population in the resume file, if there is one
+func Resume(genomes []Genome) []Genome {
+	if *ResumeFile == "" {
+		return genomes
+	}
+	resumed, err := LoadPopulation(*ResumeFile, genomes[0].Network, *Genomes)
+	if err != nil {
+		panic(err)
+	}
+	return resumed
+}
+
+// SaveFinalPopulation saves the evolved genomes to the population file, if there is one
+func SaveFinalPopulation(genomes []Genome) {
+	if *PopulationFile == "" {
+		return
+	}
+	err := SavePopulation(*PopulationFile, genomes)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// CheckpointPopulation atomically saves the genomes of a generation to the checkpoint file for the generation
+func CheckpointPopulation(generation int, genomes []Genome) {
+	err := SavePopulation(fmt.Sprintf("%s_%d.gob", *CheckpointFile, generation), genomes)
+	if err != nil {
+		panic(err)
+	}
+}
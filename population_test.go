@@ -0,0 +1,132 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testGenomes creates a population of n small real networks
+func testGenomes(n int) []Genome {
+	genomes := make([]Genome, n)
+	for i := range genomes {
+		network := testRealNetwork(4, 4, 3)
+		network[0].Weights[0] = float32(i)
+		genomes[i].Network = network
+	}
+	return genomes
+}
+
+func TestCheckpointPopulation(t *testing.T) {
+	defer func(file string) {
+		*CheckpointFile = file
+	}(*CheckpointFile)
+	*CheckpointFile = filepath.Join(t.TempDir(), "checkpoint")
+	rnd, data := Rand(LFSRInit), SyntheticDataset(4, 3, 30, 1).Samples
+	var checkpoints [][]Genome
+	Evolve(Evolution{
+		Population:      8,
+		Generations:     5,
+		Crossovers:      4,
+		Mutations:       4,
+		Rand:            &rnd,
+		CheckpointEvery: 2,
+		Checkpoint: func(generation int, genomes []Genome) {
+			CheckpointPopulation(generation, genomes)
+			checkpoint := make([]Genome, len(genomes))
+			copy(checkpoint, genomes)
+			checkpoints = append(checkpoints, checkpoint)
+		},
+		Fitness: func(network interface{}) float32 {
+			return Fitness(network.(RealNetwork), data)
+		},
+		Crossover: func(a, b interface{}) []interface{} {
+			return []interface{}{a.(RealNetwork).Copy(), b.(RealNetwork).Copy()}
+		},
+		Mutate: func(network interface{}) interface{} {
+			mutated := network.(RealNetwork).Copy()
+			mutated[0].Biases[0] += 2*rnd.Float32() - 1
+			return mutated
+		},
+	}, testGenomes(8))
+	if len(checkpoints) != 2 {
+		t.Fatalf("%d checkpoints, expected 2", len(checkpoints))
+	}
+	for generation := 0; generation < 5; generation++ {
+		file := fmt.Sprintf("%s_%d.gob", *CheckpointFile, generation)
+		_, err := os.Stat(file)
+		if generation != 1 && generation != 3 {
+			if err == nil {
+				t.Errorf("checkpoint %s exists, expected checkpoints after generations 1 and 3", file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		genomes, err := LoadPopulation(file, RealNetwork{}, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, genome := range genomes {
+			if !reflect.DeepEqual(genome.Network, checkpoints[generation/2][i].Network) {
+				t.Fatalf("genome %d of %s differs from the checkpointed genome", i, file)
+			}
+		}
+	}
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(*CheckpointFile), "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temporary files %v were left behind", matches)
+	}
+}
+
+func TestLoadPopulationSize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "population.json")
+	if err := SavePopulation(file, testGenomes(4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPopulation(file, RealNetwork{}, 8); err == nil {
+		t.Fatal("population of 4 genomes loaded as 8 genomes, expected an error")
+	}
+	genomes, err := LoadPopulation(file, RealNetwork{}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(genomes[3].Network, testGenomes(4)[3].Network) {
+		t.Fatal("loaded genome differs from the saved genome")
+	}
+}
+
+func TestResume(t *testing.T) {
+	defer func(file string, genomes int) {
+		*ResumeFile, *Genomes = file, genomes
+	}(*ResumeFile, *Genomes)
+	*ResumeFile, *Genomes = "", 4
+	initial := testGenomes(4)
+	if resumed := Resume(initial); !reflect.DeepEqual(resumed, initial) {
+		t.Fatal("resume without a file changed the genomes")
+	}
+	saved := testGenomes(4)
+	for i := range saved {
+		saved[i].Network.(RealNetwork)[1].Biases[0] = float32(i) + .5
+	}
+	*ResumeFile = filepath.Join(t.TempDir(), "population.gob")
+	if err := SavePopulation(*ResumeFile, saved); err != nil {
+		t.Fatal(err)
+	}
+	resumed := Resume(initial)
+	for i := range resumed {
+		if !reflect.DeepEqual(resumed[i].Network, saved[i].Network) {
+			t.Fatalf("resumed genome %d differs from the saved genome", i)
+		}
+	}
+}
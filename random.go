@@ -7,16 +7,16 @@ package main
 import (
 	"fmt"
 	"math"
-	"sort"
-
-	"github.com/pointlander/datum/iris"
 )
 
 // RandomLayer is a random neural network layer
+// Scale multiplies the random weights and bias, it is the only learned parameter of the layer
 type RandomLayer struct {
-	Rows    int
-	Columns int
-	Rand    Rand
+	Rows       int
+	Columns    int
+	Rand       Rand
+	Scale      float32
+	Activation string
 }
 
 // RandomNetwork is a random neural network
@@ -26,21 +26,23 @@ type RandomNetwork []RandomLayer
 func (n RandomNetwork) Inference(inputs, outputs []float32) {
 	last := len(n) - 1
 	for i, layer := range n {
-		rnd := layer.Rand
+		activation := LookupActivation(layer.Activation)
+		rnd := NewSource(layer.Rand)
 		columns := len(outputs)
 		if i < len(n)-1 {
 			columns = n[i+1].Columns
 		}
-		values, factor :=
+		values, row, factor :=
 			make([]float32, columns),
+			make([]float32, len(inputs)),
 			float32(math.Sqrt(2/float64(columns)))
 		for j := 0; j < layer.Rows; j++ {
-			sum := (2*rnd.Float32() - 1) * factor
-			for _, input := range inputs {
-				sum += input * (2*rnd.Float32() - 1) * factor
+			sum := (2*rnd.Float32() - 1) * factor * layer.Scale
+			MaterializeRow(rnd, row, -1)
+			for k, input := range inputs {
+				sum += input * row[k] * factor * layer.Scale
 			}
-			e := float32(math.Exp(float64(sum)))
-			values[j] = e / (e + 1)
+			values[j] = activation(sum)
 		}
 		if i == last {
 			copy(outputs, values)
@@ -50,130 +52,243 @@ func (n RandomNetwork) Inference(inputs, outputs []float32) {
 	}
 }
 
+// Predict returns the index of the largest output of the network
+func (n RandomNetwork) Predict(inputs []float32) int {
+	outputs := make([]float32, n[len(n)-1].Rows)
+	n.Inference(inputs, outputs)
+	return Argmax(outputs)
+}
+
+// EffectiveWeights materializes the weights layer i uses for an input of length inputs, a row of inputs weights per neuron
+func (n RandomNetwork) EffectiveWeights(i, inputs int) []float32 {
+	layer := n[i]
+	rnd := NewSource(layer.Rand)
+	columns := n[len(n)-1].Rows
+	if i < len(n)-1 {
+		columns = n[i+1].Columns
+	}
+	weights, factor :=
+		make([]float32, layer.Rows*inputs),
+		float32(math.Sqrt(2/float64(columns)))
+	for j := 0; j < layer.Rows; j++ {
+		// skip the bias
+		rnd.Float32()
+		row := weights[j*inputs : (j+1)*inputs]
+		MaterializeRow(rnd, row, -1)
+		for k := range row {
+			row[k] *= factor * layer.Scale
+		}
+	}
+	return weights
+}
+
+// WeightStats computes the statistics of the effective weights of layer i for an input of length inputs
+func (n RandomNetwork) WeightStats(i, inputs int) WeightStats {
+	return NewWeightStats(n.EffectiveWeights(i, inputs))
+}
+
+// NumParameters counts the scales of the network, the random seeds are not counted
+func (n RandomNetwork) NumParameters() int {
+	return len(n)
+}
+
+// Validate checks that the network is well formed
+func (n RandomNetwork) Validate() error {
+	if len(n) == 0 {
+		return fmt.Errorf("network has no layers")
+	}
+	previous := 0
+	for i, layer := range n {
+		if err := validateColumns(i, layer.Columns, previous); err != nil {
+			return err
+		}
+		if layer.Rows <= 0 {
+			return fmt.Errorf("layer %d has %d rows, expected a positive number", i, layer.Rows)
+		}
+		if err := validateRand(i, layer.Rand); err != nil {
+			return err
+		}
+		if err := validateActivation(i, layer.Activation); err != nil {
+			return err
+		}
+		previous = layer.Rows
+	}
+	return nil
+}
+
+// defaults sets the zero Scale of the layers of a network saved before the layers had a Scale to 1
+// A zero Scale makes every output the activation of zero, so it is never a learned scale
+func (n RandomNetwork) defaults() {
+	for i := range n {
+		if n[i].Scale == 0 {
+			n[i].Scale = 1
+		}
+	}
+}
+
+// SquaredNorm is the sum of the squared scales of the layers, the only learned weights of a random network
+func (n RandomNetwork) SquaredNorm() float32 {
+	sum := float32(0)
+	for _, layer := range n {
+		sum += layer.Scale * layer.Scale
+	}
+	return sum
+}
+
 // Copy copies a network
 func (n RandomNetwork) Copy() RandomNetwork {
 	var network RandomNetwork
 	for _, layer := range n {
 		l := RandomLayer{
-			Rows:    layer.Rows,
-			Columns: layer.Columns,
-			Rand:    layer.Rand,
+			Rows:       layer.Rows,
+			Columns:    layer.Columns,
+			Rand:       layer.Rand,
+			Scale:      layer.Scale,
+			Activation: layer.Activation,
 		}
 		network = append(network, l)
 	}
 	return network
 }
 
-// RandomNetworkModel is the real network model
+// RandomModel is the random network model
+type RandomModel struct{}
+
+// Train trains the model
+func (RandomModel) Train(seed int) float64 {
+	return RandomNetworkModel(seed)
+}
+
+// RandomNetworkModel is the random network model
 func RandomNetworkModel(seed int) float64 {
+	_, quality := RandomNetworkModelBest(seed)
+	return quality
+}
+
+// RandomNetworkModelBest is the random network model that returns the best network
+func RandomNetworkModelBest(seed int) (RandomNetwork, float64) {
+	network, _, quality := randomNetworkModel(seed)
+	return network, quality
+}
+
+// RandomNetworkModelHistory is the random network model that returns the best fitness of each generation
+func RandomNetworkModelHistory(seed int) ([]float32, float64) {
+	_, history, quality := randomNetworkModel(seed)
+	return history, quality
+}
+
+// randomNetworkModel trains the random network model returning the best network, the best fitness of each generation, and the quality
+func randomNetworkModel(seed int) (RandomNetwork, []float32, float64) {
 	rnd := Rand(LFSRInit + seed)
-	type Genome struct {
-		Network RandomNetwork
-		Fitness float32
-	}
+	train, test := LoadData()
 	var genomes []Genome
 	addNetwork := func(i int) {
+		sizes := Architecture(train.Features, train.Classes)
 		var network RandomNetwork
-		layer := RandomLayer{
-			Rows:    4,
-			Columns: 4,
-			Rand:    Rand(LFSRInit + i + seed + NumGenomes),
-		}
-		network = append(network, layer)
-
-		layer = RandomLayer{
-			Rows:    3,
-			Columns: 4,
-			Rand:    Rand(LFSRInit + i + seed + 2*NumGenomes),
+		for l := 1; l < len(sizes); l++ {
+			network = append(network, RandomLayer{
+				Rows:       sizes[l],
+				Columns:    sizes[l-1],
+				Rand:       LayerSeed(seed, i, l),
+				Scale:      1,
+				Activation: *ActivationName,
+			})
 		}
-		network = append(network, layer)
 
 		genomes = append(genomes, Genome{
 			Network: network,
 		})
 	}
-	for i := 0; i < NumGenomes; i++ {
+	for i := 0; i < *Genomes; i++ {
 		addNetwork(i)
 	}
+	genomes = Resume(genomes)
 
-	datum, err := iris.Load()
-	if err != nil {
-		panic(err)
+	batchRnd := Rand(LFSRInit + seed + 3*(*Genomes))
+	batch := Batch(train.Samples, *BatchSize, &batchRnd)
+	current := 0
+	// Mutate perturbs the scale of a layer
+	mutate := func(n interface{}) interface{} {
+		network := n.(RandomNetwork).Copy()
+		network[rnd.IntN(len(network))].Scale += MutationScale(current) * ((2 * rnd.Float32()) - 1)
+		return network
 	}
-
-	inputs, outputs := make([]float32, 4), make([]float32, 3)
-	get := func() int {
-		for {
-			for i, genome := range genomes {
-				if rnd.Float32() > genome.Fitness {
-					return i
-				}
-			}
-		}
+	mutations := Offspring(*NumMutations)
+	if !*MutateScale {
+		mutate, mutations = nil, 0
 	}
-	i := 0
-	for {
-		for j, genome := range genomes {
-			sum := float32(0)
-			for _, flower := range datum.Fisher {
-				for k, value := range flower.Measures {
-					inputs[k] = float32(value)
-				}
-				genome.Network.Inference(inputs, outputs)
-				expected := make([]float32, 3)
-				expected[iris.Labels[flower.Label]] = 1
-				loss := float32(0)
-				for l, output := range outputs {
-					diff := expected[l] - output
-					loss += diff * diff
-				}
-				loss = float32(math.Sqrt(float64(loss)))
-				sum += loss
+	genomes, history := EvolveHistory(Evolution{
+		Population:      *Genomes,
+		Elite:           *Elite,
+		Generations:     *Generations,
+		Crossovers:      Offspring(*NumCrossovers),
+		Mutations:       mutations,
+		MaxEvaluations:  *MaxEvaluations,
+		Context:         Context,
+		Rand:            &rnd,
+		Selection:       ParentSelection,
+		Samples:         len(batch),
+		Timing:          *Timing,
+		Verbose:         *Verbose,
+		CheckpointEvery: *CheckpointEvery,
+		ShareSigma:      float32(*ShareSigma),
+		Checkpoint:      CheckpointPopulation,
+		Generation: func(generation int, genomes []Genome) {
+			current = generation
+			if *BatchSize > 0 && generation > 0 {
+				batch = Batch(train.Samples, *BatchSize, &batchRnd)
 			}
-			sum /= float32(len(datum.Fisher)) * float32(math.Sqrt(3))
-			genomes[j].Fitness = sum
-		}
-		sort.Slice(genomes, func(i, j int) bool {
-			return genomes[i].Fitness < genomes[j].Fitness
-		})
-		genomes = genomes[:NumGenomes]
-		i++
-		if i > 127 {
-			break
-		}
-
-		for i := 0; i < 256; i++ {
-			a, b := get(), get()
-			layer := rnd.Uint32() & 1
+			if *ShuffleFlag {
+				batch = Shuffle(batch, &rnd)
+			}
+		},
+		Fitness: func(network interface{}) float32 {
+			outputs, sum := make([]float32, train.Classes), float32(0)
+			for _, sample := range batch {
+				network.(RandomNetwork).Inference(sample.Inputs, outputs)
+				expected := make([]float32, train.Classes)
+				expected[sample.Label] = 1
+				sum += FitnessLoss.Compute(expected, outputs)
+			}
+			sum /= float32(len(batch)) * NormalizingLoss(FitnessLoss, train.Classes)
+			return Penalize(sum, network.(RandomNetwork))
+		},
+		// Crossover swaps the random seed of a layer between the parents producing two children
+		// The seeds are swapped rather than combined with xor because the xor of equal seeds is the zero state,
+		// which the lfsr never leaves
+		Crossover: func(a, b interface{}) []interface{} {
+			layer := rnd.IntN(len(a.(RandomNetwork)))
 			networkA, networkB :=
-				genomes[a].Network.Copy(), genomes[b].Network.Copy()
-			layerA, layerB := networkA[layer], networkB[layer]
-			layerA.Rand = layerA.Rand ^ layerB.Rand
-			genomes = append(genomes, Genome{
-				Network: networkA,
-			})
-		}
-	}
+				a.(RandomNetwork).Copy(), b.(RandomNetwork).Copy()
+			networkA[layer].Rand, networkB[layer].Rand =
+				networkB[layer].Rand, networkA[layer].Rand
+			return []interface{}{networkA, networkB}
+		},
+		Mutate: mutate,
+	}, genomes)
+	SaveFinalPopulation(genomes)
 
-	network := genomes[0].Network
-	misses, total := 0, 0
-	for _, flower := range datum.Fisher {
-		for k, value := range flower.Measures {
-			inputs[k] = float32(value)
-		}
-		network.Inference(inputs, outputs)
-		max, index := float32(0), 0
-		for j, output := range outputs {
-			if output > max {
-				max, index = output, j
-			}
-		}
-		if index != iris.Labels[flower.Label] {
+	network := genomes[0].Network.(RandomNetwork)
+	misses, total, matrix := 0, 0, NewConfusionMatrix(test.Labels)
+	for _, sample := range test.Samples {
+		index := network.Predict(sample.Inputs)
+		if index != sample.Label {
 			misses++
 		}
+		matrix.Add(sample.Label, index)
 		total++
 	}
 	quality := float64(misses) / float64(total)
-	fmt.Println(genomes[0].Fitness, quality)
-	return quality
+	Println(genomes[0].Fitness, quality)
+	PrintEnsembleQuality(genomes, test.Samples)
+	if *Params {
+		Println("parameters", network.NumParameters())
+	}
+	if *ConfusionFlag {
+		fmt.Fprint(Output, matrix)
+	}
+	if *MetricsFlag {
+		fmt.Fprint(Output, matrix.Metrics())
+	}
+	return network, history, quality
 }
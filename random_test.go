@@ -0,0 +1,80 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// testRandomNetwork creates a random network with the layer sizes, a scale of 1, and the activation
+func testRandomNetwork(activation string, sizes ...int) RandomNetwork {
+	var network RandomNetwork
+	for l := 1; l < len(sizes); l++ {
+		network = append(network, RandomLayer{
+			Rows:       sizes[l],
+			Columns:    sizes[l-1],
+			Rand:       LayerSeed(0, 0, l),
+			Scale:      1,
+			Activation: activation,
+		})
+	}
+	return network
+}
+
+func TestRandomScale(t *testing.T) {
+	network := testRandomNetwork("relu", 4, 4, 3)
+	scaled := network.Copy()
+	scaled[len(scaled)-1].Scale = 2
+	inputs := SyntheticDataset(4, 3, 1, 1).Samples[0].Inputs
+	outputs, scaledOutputs := make([]float32, 3), make([]float32, 3)
+	network.Inference(inputs, outputs)
+	scaled.Inference(inputs, scaledOutputs)
+	positive := false
+	for j, output := range outputs {
+		if math.Abs(float64(scaledOutputs[j]-2*output)) > 1e-6 {
+			t.Fatalf("output %d is %f with a scale of 2, expected twice %f", j, scaledOutputs[j], output)
+		}
+		positive = positive || output > 0
+	}
+	if !positive {
+		t.Fatal("every output is zero, so the scale is not tested")
+	}
+}
+
+func TestRandomScaleDefaults(t *testing.T) {
+	network := testRandomNetwork("sigmoid", 4, 4, 3)
+	for i := range network {
+		network[i].Scale = 0
+	}
+	for _, name := range []string{"random.gob", "random.json"} {
+		file := filepath.Join(t.TempDir(), name)
+		if err := Save(file, network); err != nil {
+			t.Fatal(err)
+		}
+		var loaded RandomNetwork
+		if err := Load(file, &loaded); err != nil {
+			t.Fatal(err)
+		}
+		for i, layer := range loaded {
+			if layer.Scale != 1 {
+				t.Errorf("layer %d of %s loaded with scale %f, expected 1", i, name, layer.Scale)
+			}
+		}
+		if err := SavePopulation(file, []Genome{{Network: network}}); err != nil {
+			t.Fatal(err)
+		}
+		genomes, err := LoadPopulation(file, network, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, layer := range genomes[0].Network.(RandomNetwork) {
+			if layer.Scale != 1 {
+				t.Errorf("layer %d of the population in %s loaded with scale %f, expected 1", i, name, layer.Scale)
+			}
+		}
+	}
+}
@@ -7,18 +7,19 @@ package main
 import (
 	"fmt"
 	"math"
-	"math/bits"
 	"sort"
-
-	"github.com/pointlander/datum/iris"
 )
 
 // RealLayer is a neural network layer
+// A sparse layer stores a weight per neuron, which is applied to one of the Columns inputs picked by Rand,
+// and a dense layer stores a weight for every connection in row major order
 type RealLayer struct {
-	Columns int
-	Weights []float32
-	Biases  []float32
-	Rand    Rand
+	Columns    int
+	Weights    []float32
+	Biases     []float32
+	Rand       Rand
+	Dense      bool
+	Activation string
 }
 
 // RealNetwork is a neural network
@@ -26,28 +27,108 @@ type RealNetwork []RealLayer
 
 // Inference performs inference on a neural network
 func (n RealNetwork) Inference(inputs, outputs []float32) {
+	n.inference(inputs, outputs, 0, nil)
+}
+
+// InferenceBatch performs inference on each row of inputs generating the random weights once for the whole batch
+// The outputs are identical to calling Inference on each row
+func (n RealNetwork) InferenceBatch(inputs, outputs [][]float32) {
+	cached := NewCachedNetwork(n)
+	for i, input := range inputs {
+		cached.Inference(input, outputs[i])
+	}
+}
+
+// DropoutInference performs inference on a neural network where each input connection is dropped with probability p
+// The mask of each layer is drawn from masks, which starts as the Rand of the layers and advances with every call,
+// so the mask varies across calls while the random weights stay the same
+func (n RealNetwork) DropoutInference(inputs, outputs []float32, p float32, masks []Rand) {
+	n.inference(inputs, outputs, p, masks)
+}
+
+// Rands returns a copy of the Rand of each layer
+func (n RealNetwork) Rands() []Rand {
+	rands := make([]Rand, len(n))
+	for i, layer := range n {
+		rands[i] = layer.Rand
+	}
+	return rands
+}
+
+// inference performs inference on a neural network dropping input connections with probability p if masks is not nil
+func (n RealNetwork) inference(inputs, outputs []float32, p float32, masks []Rand) {
 	last := len(n) - 1
 	for i, layer := range n {
-		rnd := layer.Rand
+		activation := LookupActivation(layer.Activation)
+		rnd := NewSource(layer.Rand)
+		var mask *Rand
+		if masks != nil {
+			mask = &masks[i]
+		}
 		columns := len(outputs)
 		if i < len(n)-1 {
 			columns = n[i+1].Columns
 		}
-		mask, values, factor :=
-			uint32((1<<bits.TrailingZeros(uint(layer.Columns)))-1),
+		values, row, factor :=
 			make([]float32, columns),
+			make([]float32, len(inputs)),
 			float32(math.Sqrt(2/float64(columns)))
-		for j, weight := range layer.Weights {
-			sum, index := layer.Biases[j], rnd.Uint32()&mask
-			for k, input := range inputs {
-				if k == int(index) {
-					sum += input * weight
-				} else {
-					sum += input * (2*rnd.Float32() - 1) * factor
+		for j, bias := range layer.Biases {
+			sum := bias
+			if layer.Dense {
+				for k, input := range inputs {
+					if mask != nil && mask.Float32() <= p {
+						continue
+					}
+					sum += input * layer.Weights[j*layer.Columns+k]
+				}
+			} else {
+				index := int(rnd.Uint32() % uint32(layer.Columns))
+				MaterializeRow(rnd, row, index)
+				for k, input := range inputs {
+					var contribution float32
+					if k == index {
+						contribution = input * layer.Weights[j]
+					} else {
+						contribution = input * row[k] * factor
+					}
+					if mask != nil && mask.Float32() <= p {
+						continue
+					}
+					sum += contribution
 				}
 			}
-			e := float32(math.Exp(float64(sum)))
-			values[j] = e / (e + 1)
+			values[j] = activation(sum)
+		}
+		if i == last {
+			copy(outputs, values)
+		} else {
+			inputs = values
+		}
+	}
+}
+
+// HardInference performs inference on a neural network using only the learned weights
+// Each neuron connects its learned weight to the input with the same index, so the output does not depend on Rand
+func (n RealNetwork) HardInference(inputs, outputs []float32) {
+	last := len(n) - 1
+	for i, layer := range n {
+		activation := LookupActivation(layer.Activation)
+		columns := len(outputs)
+		if i < len(n)-1 {
+			columns = n[i+1].Columns
+		}
+		values := make([]float32, columns)
+		for j, bias := range layer.Biases {
+			sum, index := bias, j%layer.Columns
+			if layer.Dense {
+				for k, input := range inputs {
+					sum += input * layer.Weights[j*layer.Columns+k]
+				}
+			} else if index < len(inputs) {
+				sum += inputs[index] * layer.Weights[j]
+			}
+			values[j] = activation(sum)
 		}
 		if i == last {
 			copy(outputs, values)
@@ -62,10 +143,12 @@ func (n RealNetwork) Copy() RealNetwork {
 	var network RealNetwork
 	for _, layer := range n {
 		l := RealLayer{
-			Columns: layer.Columns,
-			Weights: make([]float32, len(layer.Weights)),
-			Biases:  make([]float32, len(layer.Biases)),
-			Rand:    layer.Rand,
+			Columns:    layer.Columns,
+			Weights:    make([]float32, len(layer.Weights)),
+			Biases:     make([]float32, len(layer.Biases)),
+			Rand:       layer.Rand,
+			Activation: layer.Activation,
+			Dense:      layer.Dense,
 		}
 		copy(l.Weights, layer.Weights)
 		copy(l.Biases, layer.Biases)
@@ -74,109 +157,579 @@ func (n RealNetwork) Copy() RealNetwork {
 	return network
 }
 
-// RealNetworkModel is the real network model
-func RealNetworkModel(seed int) float64 {
-	rnd := Rand(LFSRInit + seed)
-	type Genome struct {
-		Network RealNetwork
-		Fitness float32
+// NumParameters counts the learned weights and biases of the network
+func (n RealNetwork) NumParameters() int {
+	count := 0
+	for _, layer := range n {
+		count += len(layer.Weights) + len(layer.Biases)
 	}
-	var genomes []Genome
-	addNetwork := func(i int) {
-		var network RealNetwork
-		layer := RealLayer{
-			Columns: 4,
-			Weights: make([]float32, 4),
-			Biases:  make([]float32, 4),
-			Rand:    Rand(LFSRInit + i + seed + NumGenomes),
+	return count
+}
+
+// Validate checks that the network is well formed
+func (n RealNetwork) Validate() error {
+	if len(n) == 0 {
+		return fmt.Errorf("network has no layers")
+	}
+	previous := 0
+	for i, layer := range n {
+		if err := validateColumns(i, layer.Columns, previous); err != nil {
+			return err
+		}
+		rows := len(layer.Biases)
+		if rows == 0 {
+			return fmt.Errorf("layer %d has no rows", i)
+		}
+		weights := rows
+		if layer.Dense {
+			weights = rows * layer.Columns
+		}
+		if len(layer.Weights) != weights {
+			return fmt.Errorf("layer %d has %d weights, expected %d for %d rows", i, len(layer.Weights), weights, rows)
+		}
+		if err := validateRand(i, layer.Rand); err != nil {
+			return err
+		}
+		if err := validateActivation(i, layer.Activation); err != nil {
+			return err
+		}
+		previous = rows
+	}
+	return nil
+}
+
+// SetWeight sets a weight of a layer
+func (n RealNetwork) SetWeight(layer, index int, value float32) error {
+	if layer < 0 || layer >= len(n) {
+		return fmt.Errorf("layer %d out of range [0, %d)", layer, len(n))
+	}
+	if index < 0 || index >= len(n[layer].Weights) {
+		return fmt.Errorf("weight %d out of range [0, %d)", index, len(n[layer].Weights))
+	}
+	n[layer].Weights[index] = value
+	return nil
+}
+
+// SameShape determines if two networks have the same topology
+func (n RealNetwork) SameShape(m RealNetwork) bool {
+	if len(n) != len(m) {
+		return false
+	}
+	for i := range n {
+		if n[i].Columns != m[i].Columns || len(n[i].Weights) != len(m[i].Weights) ||
+			len(n[i].Biases) != len(m[i].Biases) || n[i].Dense != m[i].Dense {
+			return false
+		}
+	}
+	return true
+}
+
+// WeightDistance computes the euclidean distance between the weights and biases of two networks
+// NaN is returned if the networks have different shapes
+func WeightDistance(a, b RealNetwork) float32 {
+	if !a.SameShape(b) {
+		return float32(math.NaN())
+	}
+	sum := float32(0)
+	for i := range a {
+		for j, weight := range a[i].Weights {
+			diff := weight - b[i].Weights[j]
+			sum += diff * diff
 		}
-		factor := float32(math.Sqrt(2 / float64(4)))
-		for i := range layer.Weights {
-			layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+		for j, bias := range a[i].Biases {
+			diff := bias - b[i].Biases[j]
+			sum += diff * diff
 		}
-		network = append(network, layer)
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+// Rerandomize replaces the random connection seed of each layer, preserving the learned weights
+func (n RealNetwork) Rerandomize(rnd *Rand) {
+	for i := range n {
+		n[i].Rand = Rand(rnd.Uint32())
+	}
+}
 
-		layer = RealLayer{
-			Columns: 4,
-			Weights: make([]float32, 3),
-			Biases:  make([]float32, 3),
-			Rand:    Rand(LFSRInit + i + seed + 2*NumGenomes),
+// EffectiveWeights materializes the weights layer i uses for an input of length inputs, a row of inputs weights per neuron
+func (n RealNetwork) EffectiveWeights(i, inputs int) []float32 {
+	layer := n[i]
+	rows := len(layer.Biases)
+	weights := make([]float32, rows*inputs)
+	if layer.Dense {
+		for j := 0; j < rows; j++ {
+			for k := 0; k < inputs && k < layer.Columns; k++ {
+				weights[j*inputs+k] = layer.Weights[j*layer.Columns+k]
+			}
 		}
-		factor = float32(math.Sqrt(2 / float64(3)))
-		for i := range layer.Weights {
-			layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+		return weights
+	}
+	rnd := NewSource(layer.Rand)
+	columns := n.Outputs()
+	if i < len(n)-1 {
+		columns = n[i+1].Columns
+	}
+	factor := float32(math.Sqrt(2 / float64(columns)))
+	for j := 0; j < rows; j++ {
+		index, row := int(rnd.Uint32()%uint32(layer.Columns)), weights[j*inputs:(j+1)*inputs]
+		MaterializeRow(rnd, row, index)
+		for k := range row {
+			if k == index {
+				row[k] = layer.Weights[j]
+			} else {
+				row[k] *= factor
+			}
 		}
-		network = append(network, layer)
+	}
+	return weights
+}
 
-		genomes = append(genomes, Genome{
-			Network: network,
-		})
+// WeightStats computes the statistics of the effective weights of layer i for an input of length inputs
+func (n RealNetwork) WeightStats(i, inputs int) WeightStats {
+	return NewWeightStats(n.EffectiveWeights(i, inputs))
+}
+
+// SquaredNorm is the sum of the squares of the learned weights of the network, the biases are not penalized
+func (n RealNetwork) SquaredNorm() float32 {
+	sum := float32(0)
+	for _, layer := range n {
+		for _, weight := range layer.Weights {
+			sum += weight * weight
+		}
 	}
-	for i := 0; i < NumGenomes; i++ {
-		addNetwork(i)
+	return sum
+}
+
+// Outputs is the number of outputs of the network
+func (n RealNetwork) Outputs() int {
+	return len(n[len(n)-1].Biases)
+}
+
+// Predict returns the index of the largest output of the network
+func (n RealNetwork) Predict(inputs []float32) int {
+	outputs := make([]float32, n.Outputs())
+	n.Inference(inputs, outputs)
+	return Argmax(outputs)
+}
+
+// Fitness computes the normalized loss of a network on a data set
+func Fitness(n RealNetwork, data []Sample) float32 {
+	return fitness(n.Inference, n.Outputs(), data)
+}
+
+// CachedFitness computes the normalized loss of a network on a data set generating its random weights once
+func CachedFitness(n RealNetwork, data []Sample) float32 {
+	return fitness(NewCachedNetwork(n).Inference, n.Outputs(), data)
+}
+
+// DropoutFitness computes the normalized loss of a network on a data set dropping input connections with probability p
+// The masks are drawn from the Rand of the layers, so the same network always sees the same masks
+func DropoutFitness(n RealNetwork, data []Sample, p float32) float32 {
+	masks := n.Rands()
+	return fitness(func(inputs, outputs []float32) {
+		n.DropoutInference(inputs, outputs, p, masks)
+	}, n.Outputs(), data)
+}
+
+// fitness computes the normalized loss of an inference function on a data set
+func fitness(inference func(inputs, outputs []float32), classes int, data []Sample) float32 {
+	outputs := make([]float32, classes)
+	sum := float32(0)
+	for _, sample := range data {
+		inference(sample.Inputs, outputs)
+		expected := make([]float32, len(outputs))
+		expected[sample.Label] = 1
+		sum += FitnessLoss.Compute(expected, outputs)
 	}
+	return sum / (float32(len(data)) * NormalizingLoss(FitnessLoss, len(outputs)))
+}
+
+// EstimateGradient estimates the gradient of the fitness with respect to each weight and bias using central differences
+func EstimateGradient(n RealNetwork, data []Sample, epsilon float32) RealNetwork {
+	return estimateGradient(n, func(n RealNetwork) float32 {
+		return Fitness(n, data)
+	}, epsilon)
+}
 
-	datum, err := iris.Load()
-	if err != nil {
-		panic(err)
+// estimateGradient estimates the gradient of a fitness function with respect to each weight and bias using central differences
+// The gradient has the shape of the network, with the gradient of each weight and bias in its place
+func estimateGradient(n RealNetwork, fitness func(n RealNetwork) float32, epsilon float32) RealNetwork {
+	gradient, network := n.Copy(), n.Copy()
+	estimate := func(parameter *float32) float32 {
+		value := *parameter
+		*parameter = value + epsilon
+		plus := fitness(network)
+		*parameter = value - epsilon
+		minus := fitness(network)
+		*parameter = value
+		return (plus - minus) / (2 * epsilon)
 	}
+	for i, layer := range network {
+		for j := range layer.Weights {
+			gradient[i].Weights[j] = estimate(&layer.Weights[j])
+		}
+		for j := range layer.Biases {
+			gradient[i].Biases[j] = estimate(&layer.Biases[j])
+		}
+	}
+	return gradient
+}
 
-	inputs, outputs := make([]float32, 4), make([]float32, 3)
-	get := func() int {
-		for {
-			for i, genome := range genomes {
-				if rnd.Float32() > genome.Fitness {
-					return i
-				}
+// Refine polishes a network with coordinate descent, moving each weight and bias by plus or minus epsilon
+// if that lowers the fitness, and returns the refined network and its fitness
+func Refine(n RealNetwork, fitness func(n RealNetwork) float32, passes int, epsilon float32) (RealNetwork, float32) {
+	network := n.Copy()
+	best := fitness(network)
+	try := func(parameter *float32) {
+		value := *parameter
+		for _, delta := range []float32{epsilon, -epsilon} {
+			*parameter = value + delta
+			if f := fitness(network); f < best {
+				best = f
+				return
 			}
 		}
+		*parameter = value
 	}
-	i := 0
-	for {
-		for j, genome := range genomes {
-			sum := float32(0)
-			for _, flower := range datum.Fisher {
-				for k, value := range flower.Measures {
-					inputs[k] = float32(value)
-				}
-				genome.Network.Inference(inputs, outputs)
-				expected := make([]float32, 3)
-				expected[iris.Labels[flower.Label]] = 1
-				loss := float32(0)
-				for l, output := range outputs {
-					diff := expected[l] - output
-					loss += diff * diff
-				}
-				loss = float32(math.Sqrt(float64(loss)))
-				sum += loss
+	for pass := 0; pass < passes; pass++ {
+		for _, layer := range network {
+			for j := range layer.Weights {
+				try(&layer.Weights[j])
+			}
+			for j := range layer.Biases {
+				try(&layer.Biases[j])
 			}
-			sum /= float32(len(datum.Fisher)) * float32(math.Sqrt(3))
-			genomes[j].Fitness = sum
 		}
-		sort.Slice(genomes, func(i, j int) bool {
-			return genomes[i].Fitness < genomes[j].Fitness
-		})
-		genomes = genomes[:NumGenomes]
-		i++
-		if i > 127 {
-			break
+	}
+	return network, best
+}
+
+// Sensitivity is the estimated gradient of the fitness with respect to a weight or bias of a layer
+type Sensitivity struct {
+	Layer    int
+	Bias     bool
+	Index    int
+	Gradient float32
+}
+
+// String formats the sensitivity as layer, weight or bias index, and gradient
+func (s Sensitivity) String() string {
+	parameter := "weight"
+	if s.Bias {
+		parameter = "bias"
+	}
+	return fmt.Sprintf("layer %d %s %d gradient %f", s.Layer, parameter, s.Index, s.Gradient)
+}
+
+// Gradient estimates the gradient of the fitness with respect to each weight and bias with central differences of epsilon
+// The sensitivities are sorted by decreasing magnitude of the gradient
+func Gradient(n RealNetwork, fitness func(n RealNetwork) float32, epsilon float32) []Sensitivity {
+	var sensitivities []Sensitivity
+	for i, layer := range estimateGradient(n, fitness, epsilon) {
+		for j, gradient := range layer.Weights {
+			sensitivities = append(sensitivities, Sensitivity{
+				Layer:    i,
+				Index:    j,
+				Gradient: gradient,
+			})
+		}
+		for j, gradient := range layer.Biases {
+			sensitivities = append(sensitivities, Sensitivity{
+				Layer:    i,
+				Bias:     true,
+				Index:    j,
+				Gradient: gradient,
+			})
+		}
+	}
+	sort.SliceStable(sensitivities, func(i, j int) bool {
+		return math.Abs(float64(sensitivities[i].Gradient)) > math.Abs(float64(sensitivities[j].Gradient))
+	})
+	return sensitivities
+}
+
+// Identity returns the identity permutation of the input features
+func Identity(features int) []int {
+	perm := make([]int, features)
+	for i := range perm {
+		perm[i] = i
+	}
+	return perm
+}
+
+// Evaluate computes the miss rate of a network on a data set
+func Evaluate(n RealNetwork, data []Sample) float64 {
+	return EvaluatePermuted(n, data, Identity(n[0].Columns))
+}
+
+// EvaluatePermuted computes the miss rate of a network on a data set with the input features permuted
+func EvaluatePermuted(n RealNetwork, data []Sample, perm []int) float64 {
+	return evaluate(n.Inference, n.Outputs(), data, perm)
+}
+
+// EvaluateHard computes the miss rate of a network on a data set using hard inference
+func EvaluateHard(n RealNetwork, data []Sample) float64 {
+	return evaluate(n.HardInference, n.Outputs(), data, Identity(n[0].Columns))
+}
+
+// EvaluateFeatureDropout computes the miss rate of a network on a data set with each input feature zeroed with probability p
+func EvaluateFeatureDropout(n RealNetwork, data []Sample, p float32, rnd *Rand) float64 {
+	return evaluate(func(inputs, outputs []float32) {
+		for k := range inputs {
+			if rnd.Float32() <= p {
+				inputs[k] = 0
+			}
+		}
+		n.Inference(inputs, outputs)
+	}, n.Outputs(), data, Identity(n[0].Columns))
+}
+
+func evaluate(inference func(inputs, outputs []float32), classes int, data []Sample, perm []int) float64 {
+	inputs, outputs := make([]float32, len(perm)), make([]float32, classes)
+	misses, total := 0, 0
+	for _, sample := range data {
+		for k, p := range perm {
+			inputs[k] = sample.Inputs[p]
+		}
+		inference(inputs, outputs)
+		if Argmax(outputs) != sample.Label {
+			misses++
+		}
+		total++
+	}
+	return float64(misses) / float64(total)
+}
+
+// ExpectedCalibrationError computes the expected calibration error of a network on a data set
+// The confidence of a prediction is the winning output divided by the sum of the outputs
+func ExpectedCalibrationError(n RealNetwork, data []Sample, bins int) float64 {
+	outputs := make([]float32, n.Outputs())
+	confidence, correct, counts :=
+		make([]float64, bins), make([]float64, bins), make([]int, bins)
+	for _, sample := range data {
+		n.Inference(sample.Inputs, outputs)
+		index, sum := Argmax(outputs), float32(0)
+		for _, output := range outputs {
+			sum += output
+		}
+		max := outputs[index]
+		c := 0.0
+		if sum > 0 {
+			c = float64(max / sum)
+		}
+		bin := int(c * float64(bins))
+		if bin >= bins {
+			bin = bins - 1
+		}
+		confidence[bin] += c
+		if index == sample.Label {
+			correct[bin]++
+		}
+		counts[bin]++
+	}
+	ece := 0.0
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		ece += math.Abs(confidence[i]-correct[i]) / float64(len(data))
+	}
+	return ece
+}
+
+// DecisionGrid computes the predicted labels over a grid of two varied features with the others fixed
+// fixed has a value for each feature of the training set, and the varied features span their range over its samples,
+// so the training set must be normalized if the network was trained on normalized features
+func DecisionGrid(n RealNetwork, train Dataset, fixed []float32, vary [2]int, steps int) [][]int {
+	if len(fixed) != train.Features {
+		panic(fmt.Errorf("%d fixed features, expected the %d features of the training set", len(fixed), train.Features))
+	}
+	if len(train.Samples) == 0 {
+		panic(fmt.Errorf("training set has no samples"))
+	}
+	var low, high [2]float32
+	for k, feature := range vary {
+		low[k], high[k] = train.Samples[0].Inputs[feature], train.Samples[0].Inputs[feature]
+		for _, sample := range train.Samples[1:] {
+			if value := sample.Inputs[feature]; value < low[k] {
+				low[k] = value
+			} else if value > high[k] {
+				high[k] = value
+			}
+		}
+	}
+	inputs, outputs := make([]float32, len(fixed)), make([]float32, n.Outputs())
+	copy(inputs, fixed)
+	grid := make([][]int, steps)
+	for a := range grid {
+		grid[a] = make([]int, steps)
+		for b := range grid[a] {
+			if steps > 1 {
+				inputs[vary[0]] = low[0] + (high[0]-low[0])*float32(a)/float32(steps-1)
+				inputs[vary[1]] = low[1] + (high[1]-low[1])*float32(b)/float32(steps-1)
+			}
+			n.Inference(inputs, outputs)
+			grid[a][b] = Argmax(outputs)
+		}
+	}
+	return grid
+}
+
+// Confusion computes the confusion matrix of a network on a data set
+func Confusion(n RealNetwork, data Dataset) ConfusionMatrix {
+	matrix := NewConfusionMatrix(data.Labels)
+	for _, sample := range data.Samples {
+		matrix.Add(sample.Label, n.Predict(sample.Inputs))
+	}
+	return matrix
+}
+
+// AccuracyOnDataset computes the per class precision, recall, and F1 score of a network on a data set
+func AccuracyOnDataset(n RealNetwork, data Dataset) Metrics {
+	return Confusion(n, data).Metrics()
+}
+
+// BestOverSeeds trains the real network model over n seeds and returns the best network
+func BestOverSeeds(startSeed, n int) (RealNetwork, float64) {
+	var best RealNetwork
+	min := math.MaxFloat64
+	for seed := startSeed; seed < startSeed+n; seed++ {
+		network, quality := RealNetworkModelBest(seed * NumGenomes)
+		if quality < min {
+			best, min = network, quality
+		}
+	}
+	return best, min
+}
+
+// RealModel is the real network model
+type RealModel struct{}
+
+// Train trains the model
+func (RealModel) Train(seed int) float64 {
+	return RealNetworkModel(seed)
+}
+
+// RealNetworkModel is the real network model
+func RealNetworkModel(seed int) float64 {
+	_, quality := RealNetworkModelBest(seed)
+	return quality
+}
+
+// RealNetworkModelBest is the real network model that returns the best network
+func RealNetworkModelBest(seed int) (RealNetwork, float64) {
+	network, _, _, quality := realNetworkModel(seed, 0)
+	return network, quality
+}
+
+// RealNetworkModelHistory is the real network model that returns the best fitness of each generation
+func RealNetworkModelHistory(seed int) ([]float32, float64) {
+	_, history, _, quality := realNetworkModel(seed, 0)
+	return history, quality
+}
+
+// RealNetworkModelSnapshots is the real network model that returns a copy of the best network every snapshotEvery generations
+func RealNetworkModelSnapshots(seed, snapshotEvery int) ([]RealNetwork, float64) {
+	_, _, snapshots, quality := realNetworkModel(seed, snapshotEvery)
+	return snapshots, quality
+}
+
+// realNetworkModel trains the real network model returning the best network, the best fitness of each generation,
+// the snapshots of the best network every snapshotEvery generations, and the quality
+func realNetworkModel(seed, snapshotEvery int) (RealNetwork, []float32, []RealNetwork, float64) {
+	rnd := Rand(LFSRInit + seed)
+	train, test := LoadData()
+	var genomes []Genome
+	addNetwork := func(i int) {
+		sizes := Architecture(train.Features, train.Classes)
+		if *MixedTopology {
+			for l := 1; l < len(sizes)-1; l++ {
+				sizes[l] = HiddenSizes[i%len(HiddenSizes)]
+			}
+		}
+		var network RealNetwork
+		for l := 1; l < len(sizes); l++ {
+			weights := sizes[l]
+			if *DenseFlag {
+				weights *= sizes[l-1]
+			}
+			layer := RealLayer{
+				Columns:    sizes[l-1],
+				Weights:    make([]float32, weights),
+				Biases:     make([]float32, sizes[l]),
+				Rand:       LayerSeed(seed, i, l),
+				Dense:      *DenseFlag,
+				Activation: *ActivationName,
+			}
+			factor := float32(math.Sqrt(2 / float64(sizes[l])))
+			for i := range layer.Weights {
+				layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+			}
+			network = append(network, layer)
 		}
 
-		for i := 0; i < 256; i++ {
-			a, b := get(), get()
-			layer, vector, valueA, valueB :=
-				rnd.Uint32()&1, rnd.Uint32()&1, rnd.Uint32()&3, rnd.Uint32()&3
+		genomes = append(genomes, Genome{
+			Network: network,
+		})
+	}
+	for i := 0; i < *Genomes; i++ {
+		addNetwork(i)
+	}
+	genomes = Resume(genomes)
+
+	var champion RealNetwork
+	var diversity []float32
+	batchRnd := Rand(LFSRInit + seed + 3*(*Genomes))
+	batch := Batch(train.Samples, *BatchSize, &batchRnd)
+	current := 0
+	score := func(n RealNetwork) float32 {
+		f := float32(0)
+		if *Dropout > 0 {
+			f = DropoutFitness(n, batch, float32(*Dropout))
+		} else if *Cache {
+			f = CachedFitness(n, batch)
+		} else {
+			f = Fitness(n, batch)
+		}
+		return Penalize(f, n)
+	}
+	genomes, history, snapshots := EvolveSnapshots(Evolution{
+		Population:      *Genomes,
+		Elite:           *Elite,
+		Generations:     *Generations,
+		Crossovers:      Offspring(*NumCrossovers),
+		Mutations:       Offspring(*NumMutations),
+		MaxEvaluations:  *MaxEvaluations,
+		Context:         Context,
+		Rand:            &rnd,
+		Selection:       ParentSelection,
+		Samples:         len(batch),
+		Timing:          *Timing,
+		Verbose:         *Verbose,
+		CheckpointEvery: *CheckpointEvery,
+		ShareSigma:      float32(*ShareSigma),
+		Checkpoint:      CheckpointPopulation,
+		SnapshotEvery:   snapshotEvery,
+		Copy: func(network interface{}) interface{} {
+			return network.(RealNetwork).Copy()
+		},
+		Fitness: func(network interface{}) float32 {
+			return score(network.(RealNetwork))
+		},
+		Crossover: func(a, b interface{}) []interface{} {
+			if *MixedTopology && !a.(RealNetwork).SameShape(b.(RealNetwork)) {
+				return nil
+			}
+			layer := rnd.IntN(len(a.(RealNetwork)))
 			networkA, networkB :=
-				genomes[a].Network.Copy(), genomes[b].Network.Copy()
+				a.(RealNetwork).Copy(), b.(RealNetwork).Copy()
 			layerA, layerB := networkA[layer], networkB[layer]
-			if layer == 1 {
-				for valueA > 2 {
-					valueA = rnd.Uint32() & 3
-				}
-				for valueB > 2 {
-					valueB = rnd.Uint32() & 3
-				}
+			vector := rnd.Uint32() & 1
+			sizeA, sizeB := len(layerA.Weights), len(layerB.Weights)
+			if vector != 0 {
+				sizeA, sizeB = len(layerA.Biases), len(layerB.Biases)
 			}
+			valueA, valueB := rnd.IntN(sizeA), rnd.IntN(sizeB)
 			if vector == 0 {
 				layerA.Weights[valueA], layerB.Weights[valueB] =
 					layerB.Weights[valueB], layerA.Weights[valueA]
@@ -184,54 +737,103 @@ func RealNetworkModel(seed int) float64 {
 				layerA.Biases[valueA], layerB.Biases[valueB] =
 					layerB.Biases[valueB], layerA.Biases[valueA]
 			}
-			genomes = append(genomes, Genome{
-				Network: networkA,
-			})
-			genomes = append(genomes, Genome{
-				Network: networkB,
-			})
-		}
-
-		for i := 0; i < NumGenomes; i++ {
-			layer, vector, value :=
-				rnd.Uint32()&1, rnd.Uint32()&1, rnd.Uint32()&3
-			network := genomes[i].Network.Copy()
-			l := network[layer]
-			if layer == 1 {
-				for value > 2 {
-					value = rnd.Uint32() & 3
-				}
+			return []interface{}{networkA, networkB}
+		},
+		Mutate: func(n interface{}) interface{} {
+			network := n.(RealNetwork).Copy()
+			l := network[rnd.IntN(len(network))]
+			vector := rnd.Uint32() & 1
+			size := len(l.Weights)
+			if vector != 0 {
+				size = len(l.Biases)
 			}
+			value := rnd.IntN(size)
 			if vector == 0 {
-				l.Weights[value] += ((2 * rnd.Float32()) - 1)
+				l.Weights[value] += MutationScale(current) * ((2 * rnd.Float32()) - 1)
 			} else {
-				l.Biases[value] += ((2 * rnd.Float32()) - 1)
+				l.Biases[value] += MutationScale(current) * ((2 * rnd.Float32()) - 1)
 			}
-			genomes = append(genomes, Genome{
-				Network: network,
-			})
+			return network
+		},
+		Generation: func(generation int, genomes []Genome) {
+			current = generation
+			if *BatchSize > 0 && generation > 0 {
+				batch = Batch(train.Samples, *BatchSize, &batchRnd)
+			}
+			if *ShuffleFlag {
+				batch = Shuffle(batch, &rnd)
+			}
+			if *Rerandomize > 0 && generation > 0 && generation%*Rerandomize == 0 {
+				for _, genome := range genomes {
+					genome.Network.(RealNetwork).Rerandomize(&rnd)
+				}
+			}
+		},
+		Selected: func(generation int, genomes []Genome) {
+			if *Diversity != "" {
+				networks := make([]RealNetwork, len(genomes))
+				for j, genome := range genomes {
+					networks[j] = genome.Network.(RealNetwork)
+				}
+				diversity = append(diversity, PopulationDiversity(networks))
+			}
+			if *Verbose {
+				fitness := make([]float32, len(genomes))
+				for j, genome := range genomes {
+					fitness[j] = genome.Fitness
+				}
+				movement := float32(0)
+				if champion != nil {
+					movement = WeightDistance(champion, genomes[0].Network.(RealNetwork))
+				}
+				champion = genomes[0].Network.(RealNetwork)
+				Println("generation", generation, "pressure", ParentPressure(fitness), "movement", movement)
+			}
+		},
+	}, genomes)
+	SaveFinalPopulation(genomes)
+
+	if *Diversity != "" {
+		err := WriteDiversity(*Diversity, diversity)
+		if err != nil {
+			panic(err)
 		}
 	}
 
-	network := genomes[0].Network
-	misses, total := 0, 0
-	for _, flower := range datum.Fisher {
-		for k, value := range flower.Measures {
-			inputs[k] = float32(value)
-		}
-		network.Inference(inputs, outputs)
-		max, index := float32(0), 0
-		for j, output := range outputs {
-			if output > max {
-				max, index = output, j
-			}
-		}
-		if index != iris.Labels[flower.Label] {
-			misses++
+	network := genomes[0].Network.(RealNetwork)
+	if *RefinePasses > 0 {
+		network, genomes[0].Fitness = Refine(network, score, *RefinePasses, float32(*RefineEpsilon))
+		genomes[0].Network = network
+	}
+	quality := Evaluate(network, test.Samples)
+	Println(genomes[0].Fitness, quality)
+	PrintEnsembleQuality(genomes, test.Samples)
+	if *Params {
+		Println("parameters", network.NumParameters())
+	}
+	if *Hard {
+		Println("hard", EvaluateHard(network, test.Samples))
+	}
+	if *ConfusionFlag {
+		fmt.Fprint(Output, Confusion(network, test))
+	}
+	if *MetricsFlag {
+		fmt.Fprint(Output, AccuracyOnDataset(network, test))
+	}
+	if *ECE {
+		Println("ece", ExpectedCalibrationError(network, test.Samples, 10))
+	}
+	if *GradientEpsilon > 0 {
+		sensitivities := Gradient(network, func(n RealNetwork) float32 {
+			return Fitness(n, train.Samples)
+		}, float32(*GradientEpsilon))
+		for _, sensitivity := range sensitivities {
+			fmt.Fprintln(Output, sensitivity)
 		}
-		total++
 	}
-	quality := float64(misses) / float64(total)
-	fmt.Println(genomes[0].Fitness, quality)
-	return quality
+	networks := make([]RealNetwork, len(snapshots))
+	for i, snapshot := range snapshots {
+		networks[i] = snapshot.Network.(RealNetwork)
+	}
+	return network, history, networks, quality
 }
@@ -0,0 +1,527 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testRealNetwork creates a real network with the layer sizes and weights drawn from a fixed seed
+func testRealNetwork(sizes ...int) RealNetwork {
+	rnd := Rand(LFSRInit)
+	var network RealNetwork
+	for l := 1; l < len(sizes); l++ {
+		layer := RealLayer{
+			Columns:    sizes[l-1],
+			Weights:    make([]float32, sizes[l]),
+			Biases:     make([]float32, sizes[l]),
+			Rand:       LayerSeed(0, 0, l),
+			Activation: "sigmoid",
+		}
+		factor := float32(math.Sqrt(2 / float64(sizes[l])))
+		for i := range layer.Weights {
+			layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+		}
+		for i := range layer.Biases {
+			layer.Biases[i] = (2*rnd.Float32() - 1) * factor
+		}
+		network = append(network, layer)
+	}
+	return network
+}
+
+func TestThreeHiddenLayers(t *testing.T) {
+	network := testRealNetwork(4, 8, 6, 5, 3)
+	if err := network.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(network) != 4 || network.Outputs() != 3 {
+		t.Fatalf("network has %d layers and %d outputs, expected 4 and 3", len(network), network.Outputs())
+	}
+	data := SyntheticDataset(4, 3, 30, 1)
+	outputs := make([]float32, network.Outputs())
+	for _, sample := range data.Samples {
+		network.Inference(sample.Inputs, outputs)
+		for j, output := range outputs {
+			if math.IsNaN(float64(output)) || output <= 0 || output >= 1 {
+				t.Fatalf("output %d is %f, expected a sigmoid output in (0, 1)", j, output)
+			}
+		}
+	}
+	if quality := Evaluate(network, data.Samples); quality < 0 || quality > 1 {
+		t.Fatalf("quality is %f, expected a miss rate", quality)
+	}
+}
+
+func TestStoredWeightIndexes(t *testing.T) {
+	// a layer with 6 inputs must be able to apply its stored weight to every input, not just the first two
+	network := testRealNetwork(6, 64, 3)
+	weights, seen := network.EffectiveWeights(0, 6), make(map[int]bool)
+	for j, weight := range network[0].Weights {
+		for k := 0; k < 6; k++ {
+			if weights[j*6+k] == weight {
+				seen[k] = true
+			}
+		}
+	}
+	if len(seen) != 6 {
+		t.Fatalf("the stored weights are applied to inputs %v, expected all 6 inputs", seen)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	network, data := testRealNetwork(4, 2, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	fitness := func(n RealNetwork) float32 {
+		return Fitness(n, data)
+	}
+	const epsilon = 1e-2
+	sensitivities := Gradient(network, fitness, epsilon)
+	if len(sensitivities) != network.NumParameters() {
+		t.Fatalf("%d sensitivities, expected one per parameter %d", len(sensitivities), network.NumParameters())
+	}
+	estimated := EstimateGradient(network, data, epsilon)
+	for i, sensitivity := range sensitivities {
+		if i > 0 && math.Abs(float64(sensitivity.Gradient)) > math.Abs(float64(sensitivities[i-1].Gradient)) {
+			t.Fatalf("sensitivities are not sorted by decreasing magnitude at %d", i)
+		}
+		perturbed := network.Copy()
+		parameter, estimate := &perturbed[sensitivity.Layer].Weights[sensitivity.Index],
+			estimated[sensitivity.Layer].Weights[sensitivity.Index]
+		if sensitivity.Bias {
+			parameter, estimate = &perturbed[sensitivity.Layer].Biases[sensitivity.Index],
+				estimated[sensitivity.Layer].Biases[sensitivity.Index]
+		}
+		if sensitivity.Gradient != estimate {
+			t.Fatalf("%v differs from the estimated gradient %f", sensitivity, estimate)
+		}
+		if math.Abs(float64(sensitivity.Gradient)) < 1e-3 {
+			continue
+		}
+		// a step against the gradient lowers the fitness
+		*parameter -= epsilon * sign(sensitivity.Gradient)
+		if f, before := fitness(perturbed), fitness(network); f >= before {
+			t.Errorf("%v: stepping against the gradient changed the fitness from %f to %f", sensitivity, before, f)
+		}
+	}
+}
+
+// sign returns 1 for a positive value and -1 otherwise
+func sign(value float32) float32 {
+	if value > 0 {
+		return 1
+	}
+	return -1
+}
+
+func TestEvaluatePermuted(t *testing.T) {
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	if identity, quality := EvaluatePermuted(network, data, Identity(4)), Evaluate(network, data); identity != quality {
+		t.Fatalf("quality with the identity permutation is %f, expected %f", identity, quality)
+	}
+	// swapping the features of the data and then swapping them back with the permutation restores the quality
+	swapped := make([]Sample, len(data))
+	for i, sample := range data {
+		swapped[i] = Sample{
+			Inputs: []float32{sample.Inputs[1], sample.Inputs[0], sample.Inputs[3], sample.Inputs[2]},
+			Label:  sample.Label,
+		}
+	}
+	if permuted, quality := EvaluatePermuted(network, swapped, []int{1, 0, 3, 2}), Evaluate(network, data); permuted != quality {
+		t.Fatalf("quality of the permuted features is %f, expected %f", permuted, quality)
+	}
+}
+
+func TestInferenceDeterministic(t *testing.T) {
+	network := testRealNetwork(4, 4, 3)
+	outputs, again := make([]float32, 3), make([]float32, 3)
+	network.Inference(testInputs, outputs)
+	network.Inference(testInputs, again)
+	if !reflect.DeepEqual(outputs, again) {
+		t.Fatalf("outputs %v and %v differ for the same inputs", outputs, again)
+	}
+}
+
+func TestHardInference(t *testing.T) {
+	network := testRealNetwork(4, 4, 3)
+	outputs := make([]float32, 3)
+	network.HardInference(testInputs, outputs)
+	rnd := Rand(LFSRInit)
+	network.Rerandomize(&rnd)
+	rerandomized := make([]float32, 3)
+	network.HardInference(testInputs, rerandomized)
+	if !reflect.DeepEqual(outputs, rerandomized) {
+		t.Fatalf("hard outputs %v changed to %v with new random seeds", outputs, rerandomized)
+	}
+	// each neuron applies its learned weight to the input with its index
+	hidden := make([]float32, 4)
+	for i := range hidden {
+		hidden[i] = Sigmoid(network[0].Biases[i] + testInputs[i]*network[0].Weights[i])
+	}
+	for j := range outputs {
+		if expected := Sigmoid(network[1].Biases[j] + hidden[j]*network[1].Weights[j]); outputs[j] != expected {
+			t.Fatalf("hard output %d is %f, expected %f", j, outputs[j], expected)
+		}
+	}
+}
+
+func TestRerandomize(t *testing.T) {
+	network := testRealNetwork(4, 4, 3)
+	rerandomized := network.Copy()
+	rnd := Rand(LFSRInit)
+	rerandomized.Rerandomize(&rnd)
+	for i := range network {
+		if rerandomized[i].Rand == network[i].Rand {
+			t.Errorf("layer %d kept its random seed", i)
+		}
+		rerandomized[i].Rand = network[i].Rand
+	}
+	if !reflect.DeepEqual(network, rerandomized) {
+		t.Fatal("rerandomizing changed the learned weights")
+	}
+}
+
+func TestRerandomizeQuality(t *testing.T) {
+	smallModels(t)
+	defer func(generations int) { *Rerandomize = generations }(*Rerandomize)
+	mean := func(generations int) (fitness, quality float64) {
+		*Rerandomize = generations
+		for seed := 0; seed < 4; seed++ {
+			history, q := RealNetworkModelHistory(seed * NumGenomes)
+			if q < 0 || q > 1 {
+				t.Fatalf("seed %d has a quality of %f, expected a miss rate", seed, q)
+			}
+			fitness, quality = fitness+float64(history[len(history)-1])/4, quality+q/4
+		}
+		return fitness, quality
+	}
+	fitness, quality := mean(0)
+	rerandomizedFitness, rerandomizedQuality := mean(2)
+	t.Logf("mean fitness %f and quality %f, %f and %f re-randomizing every 2 generations",
+		fitness, quality, rerandomizedFitness, rerandomizedQuality)
+	if fitness == rerandomizedFitness {
+		t.Fatal("re-randomizing the random connections had no effect on the trained networks")
+	}
+	again, againQuality := mean(2)
+	if again != rerandomizedFitness || againQuality != rerandomizedQuality {
+		t.Fatal("re-randomized training is not reproducible for fixed seeds")
+	}
+}
+
+func TestDecisionGrid(t *testing.T) {
+	network, train := testRealNetwork(5, 4, 3), SyntheticDataset(5, 3, 30, 1)
+	train = NewNormalization(train).Apply(train)
+	fixed := []float32{.5, -.5, 0, 0, 1}
+	grid := DecisionGrid(network, train, fixed, [2]int{2, 3}, 5)
+	if len(grid) != 5 {
+		t.Fatalf("grid has %d rows, expected 5", len(grid))
+	}
+	for a, row := range grid {
+		if len(row) != 5 {
+			t.Fatalf("row %d has %d columns, expected 5", a, len(row))
+		}
+	}
+	// the corners of the grid are at the extremes of the varied features over the training set
+	low, high := []float32{fixed[0], fixed[1], 0, 0, fixed[4]}, []float32{fixed[0], fixed[1], 0, 0, fixed[4]}
+	for _, feature := range []int{2, 3} {
+		low[feature], high[feature] = train.Samples[0].Inputs[feature], train.Samples[0].Inputs[feature]
+		for _, sample := range train.Samples {
+			low[feature] = float32(math.Min(float64(low[feature]), float64(sample.Inputs[feature])))
+			high[feature] = float32(math.Max(float64(high[feature]), float64(sample.Inputs[feature])))
+		}
+	}
+	if expected := network.Predict(low); grid[0][0] != expected {
+		t.Fatalf("label at the minimum corner of the grid is %d, expected %d", grid[0][0], expected)
+	}
+	if expected := network.Predict(high); grid[4][4] != expected {
+		t.Fatalf("label at the maximum corner of the grid is %d, expected %d", grid[4][4], expected)
+	}
+	if !reflect.DeepEqual(grid, DecisionGrid(network, train, fixed, [2]int{2, 3}, 5)) {
+		t.Fatal("grid is not reproducible")
+	}
+	// a network that ignores its inputs predicts the same label everywhere
+	constant := testRealNetwork(5, 3)
+	constant[0].Dense, constant[0].Weights, constant[0].Biases = true, make([]float32, 3*5), []float32{-1, 1, 0}
+	for a, row := range DecisionGrid(constant, train, fixed, [2]int{0, 4}, 7) {
+		for b, label := range row {
+			if label != 1 {
+				t.Fatalf("constant network predicts %d at %d,%d, expected 1", label, a, b)
+			}
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("fixed features of the wrong length did not panic")
+		}
+	}()
+	DecisionGrid(network, train, fixed[:4], [2]int{2, 3}, 5)
+}
+
+func TestEvaluateFeatureDropout(t *testing.T) {
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	rnd := Rand(LFSRInit)
+	if quality, expected := EvaluateFeatureDropout(network, data, 0, &rnd), Evaluate(network, data); quality != expected {
+		t.Fatalf("quality without feature dropout is %f, expected %f", quality, expected)
+	}
+	rnd, again := Rand(LFSRInit), Rand(LFSRInit)
+	if a, b := EvaluateFeatureDropout(network, data, .5, &rnd), EvaluateFeatureDropout(network, data, .5, &again); a != b {
+		t.Fatalf("feature dropout qualities %f and %f differ for the same seed", a, b)
+	}
+	// every feature is dropped, so every sample is classified as the class of the zero input
+	rnd = Rand(LFSRInit)
+	expected := network.Predict(make([]float32, 4))
+	misses := 0
+	for _, sample := range data {
+		if sample.Label != expected {
+			misses++
+		}
+	}
+	if quality := EvaluateFeatureDropout(network, data, 1, &rnd); quality != float64(misses)/float64(len(data)) {
+		t.Fatalf("quality with every feature dropped is %f, expected %f", quality, float64(misses)/float64(len(data)))
+	}
+}
+
+func TestWeightDistance(t *testing.T) {
+	a := testRealNetwork(4, 4, 3)
+	b := a.Copy()
+	if distance := WeightDistance(a, b); distance != 0 {
+		t.Fatalf("distance between copies is %f, expected 0", distance)
+	}
+	b[0].Weights[0] += 3
+	b[1].Biases[1] -= 4
+	if distance := WeightDistance(a, b); math.Abs(float64(distance)-5) > 1e-5 {
+		t.Fatalf("distance is %f, expected 5", distance)
+	}
+	if distance := WeightDistance(a, testRealNetwork(4, 5, 3)); !math.IsNaN(float64(distance)) {
+		t.Fatalf("distance between different shapes is %f, expected NaN", distance)
+	}
+}
+
+func TestExpectedCalibrationError(t *testing.T) {
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	ece := ExpectedCalibrationError(network, data, 10)
+	if ece < 0 || ece > 1 {
+		t.Fatalf("calibration error is %f, expected a value in [0, 1]", ece)
+	}
+	if again := ExpectedCalibrationError(network, data, 10); again != ece {
+		t.Fatalf("calibration errors %f and %f differ", ece, again)
+	}
+	// with a single bin the error is the difference between the mean confidence and the accuracy
+	confidence, outputs := 0.0, make([]float32, 3)
+	for _, sample := range data {
+		network.Inference(sample.Inputs, outputs)
+		confidence += float64(outputs[Argmax(outputs)] / (outputs[0] + outputs[1] + outputs[2]))
+	}
+	expected := math.Abs(confidence/float64(len(data)) - (1 - Evaluate(network, data)))
+	if ece := ExpectedCalibrationError(network, data, 1); math.Abs(ece-expected) > 1e-6 {
+		t.Fatalf("calibration error with one bin is %f, expected %f", ece, expected)
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	network := testRealNetwork(4, 4, 3)
+	if err := network.SetWeight(1, 2, .5); err != nil {
+		t.Fatal(err)
+	}
+	if network[1].Weights[2] != .5 {
+		t.Fatalf("weight is %f, expected .5", network[1].Weights[2])
+	}
+	for _, index := range [][2]int{{-1, 0}, {2, 0}, {0, -1}, {0, 4}, {1, 3}} {
+		if err := network.SetWeight(index[0], index[1], 1); err == nil {
+			t.Errorf("weight %d of layer %d was set, expected an error", index[1], index[0])
+		}
+	}
+}
+
+func TestPredict(t *testing.T) {
+	network, outputs := testRealNetwork(4, 4, 3), make([]float32, 3)
+	for _, sample := range SyntheticDataset(4, 3, 30, 1).Samples {
+		network.Inference(sample.Inputs, outputs)
+		if prediction := network.Predict(sample.Inputs); prediction != Argmax(outputs) {
+			t.Fatalf("prediction %d, expected the largest output %d of %v", prediction, Argmax(outputs), outputs)
+		}
+	}
+}
+
+func TestDropoutInference(t *testing.T) {
+	network, outputs, dropped := testRealNetwork(4, 4, 3), make([]float32, 3), make([]float32, 3)
+	network.Inference(testInputs, outputs)
+	masks := network.Rands()
+	network.DropoutInference(testInputs, dropped, 0, masks)
+	if !reflect.DeepEqual(outputs, dropped) {
+		t.Fatalf("outputs %v without dropout, expected %v", dropped, outputs)
+	}
+	// every connection is dropped, so each output is the activation of its bias
+	network.DropoutInference(testInputs, dropped, 1, masks)
+	for j, output := range dropped {
+		if expected := Sigmoid(network[1].Biases[j]); output != expected {
+			t.Fatalf("output %d is %f with every connection dropped, expected %f", j, output, expected)
+		}
+	}
+	// the masks are drawn from the Rand of the layers and advance with every call
+	masks, again := network.Rands(), network.Rands()
+	first, second, replayed := make([]float32, 3), make([]float32, 3), make([]float32, 3)
+	network.DropoutInference(testInputs, first, .5, masks)
+	network.DropoutInference(testInputs, second, .5, masks)
+	network.DropoutInference(testInputs, replayed, .5, again)
+	if reflect.DeepEqual(first, second) {
+		t.Fatalf("outputs %v are the same for two calls, expected different masks", first)
+	}
+	if !reflect.DeepEqual(first, replayed) {
+		t.Fatalf("outputs %v, expected %v for the same layer Rand", replayed, first)
+	}
+	data := SyntheticDataset(4, 3, 30, 1).Samples
+	if a, b := DropoutFitness(network, data, .5), DropoutFitness(network, data, .5); a != b {
+		t.Fatalf("dropout fitness is %f and then %f, expected the same fitness", a, b)
+	}
+}
+
+func TestSquaredNorm(t *testing.T) {
+	// the biases are not penalized
+	network := RealNetwork{{Columns: 2, Weights: []float32{1, -2}, Biases: []float32{3, 0}, Rand: 1}}
+	if norm := network.SquaredNorm(); norm != 5 {
+		t.Fatalf("squared norm is %f, expected 5", norm)
+	}
+	complexNetwork := ComplexNetwork{{Columns: 2, Weights: []complex64{1 + 1i, -2}, Biases: []complex64{3i, 0}, Rand: 1}}
+	if norm := complexNetwork.SquaredNorm(); norm != 6 {
+		t.Fatalf("complex squared norm is %f, expected 6", norm)
+	}
+	random := RandomNetwork{{Rows: 2, Columns: 2, Rand: 1, Scale: 1}, {Rows: 2, Columns: 2, Rand: 2, Scale: -2}}
+	if norm := random.SquaredNorm(); norm != 5 {
+		t.Fatalf("random squared norm is %f, expected 5", norm)
+	}
+}
+
+func TestPenalize(t *testing.T) {
+	defer func(l2 float64) { *L2 = l2 }(*L2)
+	data := SyntheticDataset(4, 3, 30, 1).Samples
+	network := testRealNetwork(4, 4, 3)
+	base := Fitness(network, data)
+	*L2 = 0
+	if fitness := Penalize(base, network); fitness != base {
+		t.Fatalf("fitness is %f without a penalty, expected %f", fitness, base)
+	}
+	*L2 = .25
+	for _, n := range []Normed{network, goldenShared(), goldenRandom(), goldenComplex()} {
+		if fitness, expected := Penalize(base, n), base+.25*n.SquaredNorm(); fitness != expected {
+			t.Errorf("%T fitness is %f, expected %f", n, fitness, expected)
+		}
+	}
+	larger := network.Copy()
+	for i := range larger {
+		for j := range larger[i].Weights {
+			larger[i].Weights[j] *= 2
+		}
+	}
+	if Penalize(base, larger) <= Penalize(base, network) {
+		t.Fatal("a network with larger weights has the same penalty")
+	}
+}
+
+func TestRandomL2(t *testing.T) {
+	smallModels(t)
+	defer func(l2 float64) { *L2 = l2 }(*L2)
+	*L2 = 0
+	history, _ := RandomNetworkModelHistory(NumGenomes)
+	*L2 = .5
+	penalized, _ := RandomNetworkModelHistory(NumGenomes)
+	if penalized[0] <= history[0] {
+		t.Fatalf("best fitness of the first generation is %f with -l2, expected more than %f", penalized[0], history[0])
+	}
+}
+
+func TestRefine(t *testing.T) {
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1).Samples
+	fitness := func(n RealNetwork) float32 {
+		return Fitness(n, data)
+	}
+	refined, f := Refine(network, fitness, 2, .05)
+	if before := fitness(network); f >= before {
+		t.Fatalf("refined fitness %f, expected less than %f", f, before)
+	}
+	if f != fitness(refined) {
+		t.Fatalf("refined fitness %f differs from the fitness %f of the refined network", f, fitness(refined))
+	}
+	if unchanged, _ := Refine(network, fitness, 0, .05); !reflect.DeepEqual(unchanged, network) {
+		t.Fatal("refining with no passes changed the network")
+	}
+}
+
+func TestAccuracyOnDataset(t *testing.T) {
+	network, data := testRealNetwork(4, 4, 3), SyntheticDataset(4, 3, 30, 1)
+	metrics, matrix := AccuracyOnDataset(network, data), Confusion(network, data)
+	correct := 0
+	for i := range matrix.Counts {
+		correct += matrix.Counts[i][i]
+	}
+	if expected := 1 - Evaluate(network, data.Samples); math.Abs(float64(correct)/30-expected) > 1e-9 {
+		t.Fatalf("confusion matrix has %d correct predictions, expected an accuracy of %f", correct, expected)
+	}
+	if len(metrics.Precision) != 3 || len(metrics.Recall) != 3 || len(metrics.F1) != 3 {
+		t.Fatalf("metrics %+v, expected 3 classes", metrics)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := testRealNetwork(4, 4, 3).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, change := range map[string]func(n RealNetwork) RealNetwork{
+		"no layers":  func(n RealNetwork) RealNetwork { return nil },
+		"columns":    func(n RealNetwork) RealNetwork { n[1].Columns = 5; return n },
+		"weights":    func(n RealNetwork) RealNetwork { n[0].Weights = n[0].Weights[:3]; return n },
+		"rows":       func(n RealNetwork) RealNetwork { n[1].Biases, n[1].Weights = nil, nil; return n },
+		"dense":      func(n RealNetwork) RealNetwork { n[0].Dense = true; return n },
+		"zero seed":  func(n RealNetwork) RealNetwork { n[1].Rand = 0; return n },
+		"activation": func(n RealNetwork) RealNetwork { n[0].Activation = "step"; return n },
+	} {
+		if err := change(testRealNetwork(4, 4, 3)).Validate(); err == nil {
+			t.Errorf("network with invalid %s is valid", name)
+		}
+	}
+}
+
+func TestNumParameters(t *testing.T) {
+	if n := testRealNetwork(4, 4, 3).NumParameters(); n != 14 {
+		t.Errorf("real network has %d parameters, expected 14", n)
+	}
+	if n := goldenShared().NumParameters(); n != 8 {
+		t.Errorf("shared network has %d parameters, expected 8", n)
+	}
+	if n := goldenRandom().NumParameters(); n != 2 {
+		t.Errorf("random network has %d parameters, expected 2", n)
+	}
+	if n := goldenComplex().NumParameters(); n != 28 {
+		t.Errorf("complex network has %d parameters, expected 28", n)
+	}
+}
+
+func TestDenseInference(t *testing.T) {
+	network := testNetworks()[4]
+	outputs := make([]float32, 3)
+	network.Inference(testInputs, outputs)
+	inputs := testInputs
+	for _, layer := range network {
+		values := make([]float32, len(layer.Biases))
+		for j := range values {
+			sum := layer.Biases[j]
+			for k, input := range inputs {
+				sum += input * layer.Weights[j*layer.Columns+k]
+			}
+			values[j] = Sigmoid(sum)
+		}
+		inputs = values
+	}
+	if !reflect.DeepEqual(outputs, inputs) {
+		t.Fatalf("dense outputs %v, expected %v", outputs, inputs)
+	}
+	rnd := Rand(LFSRInit)
+	network.Rerandomize(&rnd)
+	network.Inference(testInputs, outputs)
+	if !reflect.DeepEqual(outputs, inputs) {
+		t.Fatalf("dense outputs %v changed with new random seeds, expected %v", outputs, inputs)
+	}
+}
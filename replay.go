@@ -0,0 +1,34 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "fmt"
+
+// LookupFamily returns the model family with the given name
+func LookupFamily(name string) (Family, error) {
+	for _, family := range Families {
+		if family.Name == name {
+			return family, nil
+		}
+	}
+	return Family{}, fmt.Errorf("replay not supported for model %s", name)
+}
+
+// ReplayChampion retrains a model family with a seed, saves the champion to file, and returns the champion,
+// its quality, and its confusion matrix on the test set
+func ReplayChampion(family Family, seed int, file string) (interface{}, float64, ConfusionMatrix, error) {
+	network, quality := family.Model(seed * NumGenomes)
+	classifier, err := NewClassifier(network)
+	if err != nil {
+		return nil, 0, ConfusionMatrix{}, err
+	}
+	_, test := LoadData()
+	matrix := NewConfusionMatrix(test.Labels)
+	for _, sample := range test.Samples {
+		index, _ := classifier.Inference(sample.Inputs)
+		matrix.Add(sample.Label, index)
+	}
+	return network, quality, matrix, Save(file, network)
+}
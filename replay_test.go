@@ -0,0 +1,73 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testReplay replays a model family with a seed and checks that the saved champion loads and reproduces the reported quality
+func testReplay(t *testing.T, name string, seed int) {
+	family, err := LookupFamily(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "champion.gob")
+	network, quality, matrix, err := ReplayChampion(family, seed, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := reflect.New(reflect.TypeOf(network))
+	if err := Load(file, loaded.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Elem().Interface(), network) {
+		t.Fatalf("%s champion loaded from %s differs from the saved champion", name, file)
+	}
+	classifier, err := NewClassifier(loaded.Elem().Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, test := LoadData()
+	misses, correct := 0, 0
+	for _, sample := range test.Samples {
+		if index, _ := classifier.Inference(sample.Inputs); index != sample.Label {
+			misses++
+		}
+	}
+	for i := range matrix.Counts {
+		correct += matrix.Counts[i][i]
+	}
+	if reproduced := float64(misses) / float64(len(test.Samples)); reproduced != quality {
+		t.Errorf("loaded %s champion has a quality of %f, expected the reported %f", name, reproduced, quality)
+	}
+	if correct != len(test.Samples)-misses {
+		t.Errorf("%s confusion matrix has %d correct predictions, expected %d", name, correct, len(test.Samples)-misses)
+	}
+}
+
+func TestReplayReal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("trains the real model")
+	}
+	Output = discard(t)
+	testReplay(t, "real", 185)
+}
+
+func TestReplayFamilies(t *testing.T) {
+	Output = discard(t)
+	defer func(generations, genomes int) {
+		*Generations, *Genomes = generations, genomes
+	}(*Generations, *Genomes)
+	*Generations, *Genomes = 4, 16
+	for _, name := range []string{"shared", "random", "complex"} {
+		testReplay(t, name, 7)
+	}
+	if _, err := LookupFamily("rnn"); err == nil {
+		t.Error("rnn replay is supported, expected an error")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RNNModel is the recurrent neural network model
+func RNNModel(seed int) float64 {
+	_, quality := RNNModelSize(seed, *Size)
+	return quality
+}
+
+// RNNSnapshot is the state of the recurrent neural network after an iteration
+type RNNSnapshot struct {
+	Iteration   int
+	Outputs     []float32
+	Connections [][]float32
+}
+
+// RNNModelSize is the recurrent neural network model with size neurons that returns the connections matrix
+// The quality is the relative change in the standard deviation of the off diagonal connections over the last iteration,
+// so a lower quality means the connection matrix has converged
+func RNNModelSize(seed, size int) ([][]float32, float64) {
+	return RNNModelSnapshots(seed, size, nil)
+}
+
+// RNNModelSnapshots is RNNModelSize writing a json RNNSnapshot line to out after every iteration if out is not nil
+// The outputs and connections then only go to out, and the statistics of every iteration are printed instead
+func RNNModelSnapshots(seed, size int, out io.Writer) ([][]float32, float64) {
+	var encoder *json.Encoder
+	if out != nil {
+		encoder = json.NewEncoder(out)
+	}
+	g := Rand(LFSRInit + seed)
+	waves, inputs, outputs, connections, factor :=
+		make([]float32, 2), make([]float32, size), make([]float32, size), make([][]float32, size), float32(math.Sqrt(2/float64(size)))
+	for i := range connections {
+		connections[i] = make([]float32, size)
+		for j := range connections[i] {
+			connections[i][j] = 1
+		}
+	}
+	standardDeviation, previous := 0.0, 0.0
+	for i := 0; i < 1024; i++ {
+		rnd := Rand(LFSRInit + seed + 3*size*size)
+		for j := 0; j < size; j++ {
+			sum := (2*rnd.Float32() - 1) * factor
+			for _, wave := range waves {
+				sum += (2*rnd.Float32() - 1) * factor * wave
+			}
+			for k := 0; k < size; k++ {
+				if weight := rnd.Float32(); k == j {
+					sum += (2*weight - 1) * factor * inputs[k]
+				} else if g.Float32() > 1/float32(connections[j][k]) {
+					sum += (2*weight - 1) * factor * inputs[k]
+				}
+			}
+			outputs[j] = Sigmoid(sum)
+		}
+		for j, a := range outputs {
+			for k, b := range outputs {
+				if j == k {
+					continue
+				}
+				if a > .5 && b > .5 {
+					connections[j][k]++
+				} else if connections[j][k] > 1 {
+					connections[j][k]--
+				}
+			}
+		}
+		copy(inputs, outputs)
+
+		average, deviation := ConnectionStats(connections)
+		previous, standardDeviation = standardDeviation, deviation
+		if encoder != nil {
+			err := encoder.Encode(RNNSnapshot{
+				Iteration:   i,
+				Outputs:     outputs,
+				Connections: connections,
+			})
+			if err != nil {
+				panic(err)
+			}
+		}
+		if encoder == nil {
+			fmt.Fprintln(Output, i, outputs)
+			fmt.Fprintln(Output, connections)
+			if *Verbose {
+				Println(i, average, standardDeviation)
+			}
+		} else {
+			Println(i, average, standardDeviation)
+		}
+
+		switch true {
+		case waves[0] == 0 && waves[1] == 0:
+			waves[0], waves[1] = 1, 0
+		case waves[0] == 1 && waves[1] == 0:
+			waves[0], waves[1] = 0, 1
+		case waves[0] == 0 && waves[1] == 1:
+			waves[0], waves[1] = 1, 1
+		case waves[0] == 1 && waves[1] == 1:
+			waves[0], waves[1] = 0, 0
+		}
+	}
+	quality := 0.0
+	if standardDeviation > 0 {
+		quality = math.Abs(standardDeviation-previous) / standardDeviation
+	}
+	Println(standardDeviation, quality)
+	return connections, quality
+}
+
+// ConnectionStats computes the average and standard deviation of the off diagonal connections
+func ConnectionStats(connections [][]float32) (average, standardDeviation float64) {
+	size, variance := len(connections), 0.0
+	for j := range connections {
+		for k, connection := range connections[j] {
+			if j != k {
+				average += float64(connection)
+			}
+		}
+	}
+	average /= float64(size*size - size)
+	for j := range connections {
+		for k, connection := range connections[j] {
+			if j != k {
+				diff := float64(connection) - average
+				variance += diff * diff
+			}
+		}
+	}
+	return average, math.Sqrt(variance / float64(size*size-size))
+}
+
+// Adjacency thresholds the off diagonal connections into an adjacency matrix
+func Adjacency(connections [][]float32, threshold float64) [][]bool {
+	adjacency := make([][]bool, len(connections))
+	for j := range connections {
+		adjacency[j] = make([]bool, len(connections[j]))
+		for k, connection := range connections[j] {
+			adjacency[j][k] = j != k && float64(connection) > threshold
+		}
+	}
+	return adjacency
+}
+
+// WriteDOT writes the adjacency matrix as an undirected graphviz graph
+// There is an edge between neurons j and k if either of them is adjacent to the other
+func WriteDOT(out io.Writer, adjacency [][]bool) error {
+	_, err := fmt.Fprintln(out, "graph rnn {")
+	if err != nil {
+		return err
+	}
+	for j := range adjacency {
+		_, err := fmt.Fprintf(out, "\t%d;\n", j)
+		if err != nil {
+			return err
+		}
+	}
+	for j := range adjacency {
+		for k := j + 1; k < len(adjacency); k++ {
+			if !adjacency[j][k] && !adjacency[k][j] {
+				continue
+			}
+			_, err := fmt.Fprintf(out, "\t%d -- %d;\n", j, k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = fmt.Fprintln(out, "}")
+	return err
+}
+
+// RNNModelType is the recurrent neural network model
+type RNNModelType struct{}
+
+// Train trains the model
+func (RNNModelType) Train(seed int) float64 {
+	return RNNModel(seed)
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRNNModelSize(t *testing.T) {
+	Output = discard(t)
+	connections, quality := RNNModelSize(3, 6)
+	if len(connections) != 6 || len(connections[0]) != 6 {
+		t.Fatalf("connections are %dx%d, expected 6x6", len(connections), len(connections[0]))
+	}
+	again, qualityAgain := RNNModelSize(3, 6)
+	if !reflect.DeepEqual(connections, again) || quality != qualityAgain {
+		t.Fatal("recurrent model is not reproducible for a fixed seed")
+	}
+	if quality < 0 {
+		t.Fatalf("quality is %f, expected a relative change", quality)
+	}
+}
+
+func TestRNNModelOutput(t *testing.T) {
+	defer func(verbose bool) { *Verbose = verbose }(*Verbose)
+	*Verbose = false
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+	RNNModelSize(3, 6)
+	// the outputs and connections of every iteration followed by the quality
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2*1024+1 {
+		t.Fatalf("%d lines, expected %d", len(lines), 2*1024+1)
+	}
+	if !strings.HasPrefix(lines[0], "0 [") || !strings.HasPrefix(lines[1], "[[") {
+		t.Fatalf("first iteration is %q and %q, expected the outputs and connections", lines[0], lines[1])
+	}
+}
+
+func TestRNNModelSnapshots(t *testing.T) {
+	defer func(verbose bool) { *Verbose = verbose }(*Verbose)
+	*Verbose = false
+	var out, printed bytes.Buffer
+	Output = &printed
+	defer func() { Output = os.Stdout }()
+	connections, quality := RNNModelSnapshots(3, 6, &out)
+	// only the statistics of every iteration followed by the quality are printed
+	statistics := strings.Split(strings.TrimSpace(printed.String()), "\n")
+	if len(statistics) != 1024+1 {
+		t.Fatalf("%d lines printed, expected %d", len(statistics), 1024+1)
+	}
+	if fields := strings.Fields(statistics[0]); len(fields) != 3 || fields[0] != "0" || strings.Contains(statistics[0], "[") {
+		t.Fatalf("first iteration printed %q, expected the iteration, average, and standard deviation", statistics[0])
+	}
+	Output = discard(t)
+	expected, expectedQuality := RNNModelSize(3, 6)
+	if !reflect.DeepEqual(connections, expected) || quality != expectedQuality {
+		t.Fatal("snapshots changed the model")
+	}
+	scanner, lines := bufio.NewScanner(&out), 0
+	scanner.Buffer(nil, 1<<20)
+	var snapshot RNNSnapshot
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Iteration != lines {
+			t.Fatalf("snapshot %d has the iteration %d", lines, snapshot.Iteration)
+		}
+		lines++
+	}
+	if lines != 1024 {
+		t.Fatalf("%d snapshots, expected 1024", lines)
+	}
+	if !reflect.DeepEqual(snapshot.Connections, connections) {
+		t.Fatal("last snapshot differs from the connections")
+	}
+}
+
+func TestConnectionStats(t *testing.T) {
+	// the diagonal is ignored
+	average, deviation := ConnectionStats([][]float32{{100, 1, 3}, {1, 100, 3}, {1, 3, 100}})
+	if average != 2 || deviation != 1 {
+		t.Fatalf("average %f and deviation %f, expected 2 and 1", average, deviation)
+	}
+}
+
+func TestAdjacency(t *testing.T) {
+	adjacency := Adjacency([][]float32{{9, 1, 3}, {1, 9, 1}, {1, 1, 9}}, 2)
+	expected := [][]bool{{false, false, true}, {false, false, false}, {false, false, false}}
+	if !reflect.DeepEqual(adjacency, expected) {
+		t.Fatalf("adjacency %v, expected %v", adjacency, expected)
+	}
+	var out strings.Builder
+	if err := WriteDOT(&out, adjacency); err != nil {
+		t.Fatal(err)
+	}
+	if dot := "graph rnn {\n\t0;\n\t1;\n\t2;\n\t0 -- 2;\n}\n"; out.String() != dot {
+		t.Fatalf("graph is %q, expected %q", out.String(), dot)
+	}
+}
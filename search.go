@@ -0,0 +1,105 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// Result is the quality of a model trained with a seed
+type Result struct {
+	Seed    int
+	Quality float64
+}
+
+// SearchResult is the outcome of a seed search
+type SearchResult struct {
+	// BestSeed is the seed with the lowest quality
+	BestSeed int
+	// BestQuality is the lowest quality
+	BestQuality float64
+	// BelowThresholdCount is the number of seeds with a quality below 0.1
+	BelowThresholdCount int
+	// All are the results of every seed in the order they finished
+	All []Result
+}
+
+// process trains the model with SearchIterations seeds in parallel and returns the search result
+// The search stops early when ctx is done
+func process(ctx context.Context, model Model) SearchResult {
+	results := make(chan Result, runtime.NumCPU())
+	routine := func(seed int) {
+		results <- Result{
+			Seed:    seed,
+			Quality: model.Train(seed * NumGenomes),
+		}
+	}
+	var writer *csv.Writer
+	file := *SearchOut
+	if file == "" {
+		file = *Out
+	}
+	if file != "" {
+		out, err := os.Create(file)
+		if err != nil {
+			panic(err)
+		}
+		defer out.Close()
+		writer = csv.NewWriter(out)
+	}
+	search, j, flight := SearchResult{BestQuality: 1}, 0, 0
+	var qualities []float64
+	record := func(result Result) {
+		qualities = append(qualities, result.Quality)
+		search.All = append(search.All, result)
+		if result.Quality < search.BestQuality {
+			search.BestQuality, search.BestSeed = result.Quality, result.Seed
+		}
+		if result.Quality < .1 {
+			search.BelowThresholdCount++
+		}
+		if writer == nil {
+			return
+		}
+		err := writer.Write([]string{
+			strconv.Itoa(result.Seed),
+			strconv.FormatFloat(result.Quality, 'f', -1, 64),
+		})
+		if err != nil {
+			panic(err)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			panic(err)
+		}
+	}
+	for i := 0; i < runtime.NumCPU() && j < SearchIterations; i++ {
+		go routine(j)
+		j++
+		flight++
+	}
+	for j < SearchIterations {
+		record(<-results)
+		flight--
+		if ctx.Err() != nil {
+			break
+		}
+
+		go routine(j)
+		j++
+		flight++
+	}
+	for i := 0; i < flight; i++ {
+		record(<-results)
+	}
+	Println(search.BestQuality, search.BestSeed, search.BelowThresholdCount)
+	stats := Statistics(qualities)
+	Println("mean", stats.Mean, "median", stats.Median, "std", stats.StandardDeviation, "min", stats.Min, "max", stats.Max)
+	return search
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// testQuality is a quality for each seed that is lowest for seed 17
+func testQuality(seed int) float64 {
+	return math.Abs(float64(seed/NumGenomes-17)) / SearchIterations
+}
+
+// readSearchOut reads the quality of each seed from a search results file
+func readSearchOut(t *testing.T, file string) map[int]float64 {
+	in, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	records, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	qualities := make(map[int]float64, len(records))
+	for _, record := range records {
+		seed, err := strconv.Atoi(record[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := qualities[seed]; ok {
+			t.Fatalf("search file has seed %d twice", seed)
+		}
+		quality, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qualities[seed] = quality
+	}
+	return qualities
+}
+
+func TestProcessSearchOut(t *testing.T) {
+	Output = discard(t)
+	defer func(out string) { *SearchOut = out }(*SearchOut)
+	*SearchOut = filepath.Join(t.TempDir(), "search.csv")
+	process(context.Background(), ModelFunc(testQuality))
+	qualities := readSearchOut(t, *SearchOut)
+	if len(qualities) != SearchIterations {
+		t.Fatalf("search file has %d records, expected %d", len(qualities), SearchIterations)
+	}
+	for seed := 0; seed < SearchIterations; seed++ {
+		quality, ok := qualities[seed]
+		if !ok {
+			t.Fatalf("search file is missing seed %d", seed)
+		}
+		if expected := testQuality(seed * NumGenomes); quality != expected {
+			t.Errorf("seed %d has a quality of %f in the search file, expected %f", seed, quality, expected)
+		}
+	}
+}
+
+func TestProcessOut(t *testing.T) {
+	Output = discard(t)
+	defer func(out string) { *Out = out }(*Out)
+	*Out = filepath.Join(t.TempDir(), "search.csv")
+	process(context.Background(), ModelFunc(testQuality))
+	qualities := readSearchOut(t, *Out)
+	if len(qualities) != SearchIterations {
+		t.Fatalf("search file has %d records, expected %d", len(qualities), SearchIterations)
+	}
+	if quality := qualities[17]; quality != 0 {
+		t.Errorf("seed 17 has a quality of %f in the search file, expected 0", quality)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	Output = discard(t)
+	search := process(context.Background(), ModelFunc(testQuality))
+	if search.BestSeed != 17 || search.BestQuality != 0 {
+		t.Fatalf("best seed %d with a quality of %f, expected 17 with 0", search.BestSeed, search.BestQuality)
+	}
+	// the seeds 0 through 42 are less than 25.6 seeds from 17, so their quality is below .1
+	if search.BelowThresholdCount != 43 {
+		t.Fatalf("%d seeds below the threshold, expected 43", search.BelowThresholdCount)
+	}
+	if len(search.All) != SearchIterations {
+		t.Fatalf("%d results, expected %d", len(search.All), SearchIterations)
+	}
+	seeds := make([]int, len(search.All))
+	for i, result := range search.All {
+		if expected := testQuality(result.Seed * NumGenomes); result.Quality != expected {
+			t.Errorf("seed %d has a quality of %f, expected %f", result.Seed, result.Quality, expected)
+		}
+		seeds[i] = result.Seed
+	}
+	sort.Ints(seeds)
+	for i, seed := range seeds {
+		if seed != i {
+			t.Fatalf("results are missing seed %d", i)
+		}
+	}
+}
+
+func TestProcessContext(t *testing.T) {
+	Output = discard(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	search := process(ctx, ModelFunc(testQuality))
+	if len(search.All) == 0 || len(search.All) >= SearchIterations {
+		t.Fatalf("canceled search has %d results, expected the seeds in flight", len(search.All))
+	}
+	// the partial best is the best of the seeds that finished
+	for _, result := range search.All {
+		if result.Quality < search.BestQuality {
+			t.Fatalf("seed %d has a quality of %f below the best %f", result.Seed, result.Quality, search.BestQuality)
+		}
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	stats := Statistics([]float64{4, 1, 3, 2})
+	if stats.Mean != 2.5 || stats.Median != 2.5 || stats.Min != 1 || stats.Max != 4 ||
+		math.Abs(stats.StandardDeviation-math.Sqrt(1.25)) > 1e-12 {
+		t.Errorf("stats %+v, expected a mean and median of 2.5, deviation of sqrt(1.25), min of 1, and max of 4", stats)
+	}
+	if stats := Statistics([]float64{3, 1, 2}); stats.Median != 2 {
+		t.Errorf("median of an odd number of values is %f, expected 2", stats.Median)
+	}
+	if stats := Statistics(nil); stats != (Stats{}) {
+		t.Errorf("stats of no values %+v, expected zero", stats)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Selection selects the index of a parent from genomes sorted by fitness
+type Selection func(genomes []Genome, rnd *Rand) int
+
+// SelectionPasses is the number of passes over the genomes the fitness proportional selection makes before giving up
+const SelectionPasses = 1024
+
+// ProportionalSelection accepts each genome in turn with probability one minus its fitness
+// If no genome is accepted after SelectionPasses passes the genome with the lowest fitness is returned
+func ProportionalSelection(genomes []Genome, rnd *Rand) int {
+	for pass := 0; pass < SelectionPasses; pass++ {
+		for i, genome := range genomes {
+			if rnd.Float32() > genome.Fitness {
+				return i
+			}
+		}
+	}
+	best := 0
+	for i, genome := range genomes {
+		if genome.Fitness < genomes[best].Fitness {
+			best = i
+		}
+	}
+	return best
+}
+
+// TournamentSelection returns a selection that picks the best of size genomes chosen at random
+func TournamentSelection(size int) Selection {
+	return func(genomes []Genome, rnd *Rand) int {
+		best := rnd.IntN(len(genomes))
+		for i := 1; i < size; i++ {
+			j := rnd.IntN(len(genomes))
+			if genomes[j].Fitness < genomes[best].Fitness {
+				best = j
+			}
+		}
+		return best
+	}
+}
+
+// NewSelection returns the selection with the given name
+// The fitness proportional selection is nil, which Evolve applies to the whole population
+func NewSelection(name string, size int) (Selection, error) {
+	switch name {
+	case "proportional":
+		return nil, nil
+	case "tournament":
+		return TournamentSelection(size), nil
+	}
+	return nil, fmt.Errorf("unknown selection: %s", name)
+}
+
+// acceptance is the probability the fitness proportional selection accepts a genome with the fitness when it is visited
+func acceptance(fitness float32) float64 {
+	if math.IsNaN(float64(fitness)) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, 1-float64(fitness)))
+}
+
+// SelectionProbabilities computes the probability of each genome being selected by the fitness proportional selection
+// The genomes are visited in order, so genome i is selected with probability a_i * (1-a_0) * ... * (1-a_(i-1))
+// in a pass, where a_i is its acceptance probability, and this repeats for each of the SelectionPasses passes
+// If every pass rejects every genome, the genome with the lowest fitness is selected
+func SelectionProbabilities(fitness []float32) []float64 {
+	probabilities, reject := make([]float64, len(fitness)), 1.0
+	for i, f := range fitness {
+		a := acceptance(f)
+		probabilities[i] = reject * a
+		reject *= 1 - a
+	}
+	exhausted := math.Pow(reject, SelectionPasses)
+	if reject < 1 {
+		for i := range probabilities {
+			probabilities[i] *= (1 - exhausted) / (1 - reject)
+		}
+	}
+	best := 0
+	for i, f := range fitness {
+		if f < fitness[best] {
+			best = i
+		}
+	}
+	probabilities[best] += exhausted
+	return probabilities
+}
+
+// SelectionPressure computes the ratio of the best genome's probability of being selected to the mean 1/n
+// under the fitness proportional selection of the genomes in their order
+// The genomes tied for the best fitness share their selection probability, so a population of equal fitness has a pressure of 1
+func SelectionPressure(fitness []float32) float64 {
+	best, ties, selected := float32(math.Inf(1)), 0, 0.0
+	for _, f := range fitness {
+		if f < best {
+			best, ties = f, 0
+		}
+		if f == best {
+			ties++
+		}
+	}
+	if ties == 0 {
+		return 1
+	}
+	for i, p := range SelectionProbabilities(fitness) {
+		if fitness[i] == best {
+			selected += p
+		}
+	}
+	return float64(len(fitness)) * selected / float64(ties)
+}
+
+// TournamentPressure computes the ratio of the best genome's probability of being selected to the mean 1/n
+// under the tournament selection with tournaments of size genomes
+// The genomes tied for the best fitness share the tournaments they win, so a population of equal fitness has a pressure of 1
+func TournamentPressure(fitness []float32, size int) float64 {
+	best, ties := float32(math.Inf(1)), 0
+	for _, f := range fitness {
+		if f < best {
+			best, ties = f, 0
+		}
+		if f == best {
+			ties++
+		}
+	}
+	n := float64(len(fitness))
+	return n * (1 - math.Pow(1-float64(ties)/n, float64(size))) / float64(ties)
+}
+
+// ParentPressure computes the selection pressure of the parent selection selected with -selection
+func ParentPressure(fitness []float32) float64 {
+	if *SelectionName == "tournament" {
+		return TournamentPressure(fitness, *TournamentSize)
+	}
+	return SelectionPressure(fitness)
+}
+
+// ParentSelection is the selection the models use to pick crossover parents, nil for the fitness proportional selection
+var ParentSelection Selection
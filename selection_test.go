@@ -0,0 +1,120 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// testFitness returns the fitness of n genomes that all have the fitness other except the first, which has best
+func testFitness(n int, best, other float32) []float32 {
+	fitness := make([]float32, n)
+	for i := range fitness {
+		fitness[i] = other
+	}
+	fitness[0] = best
+	return fitness
+}
+
+func TestSelectionPressureUniform(t *testing.T) {
+	for _, n := range []int{16, 256} {
+		if pressure := SelectionPressure(testFitness(n, .3, .3)); math.Abs(pressure-1) > 1e-9 {
+			t.Errorf("uniform population of %d has a pressure of %f, expected 1", n, pressure)
+		}
+		if pressure := TournamentPressure(testFitness(n, .3, .3), 4); math.Abs(pressure-1) > 1e-9 {
+			t.Errorf("uniform population of %d has a tournament pressure of %f, expected 1", n, pressure)
+		}
+	}
+}
+
+func TestSelectionProbabilities(t *testing.T) {
+	// the first genome is accepted with probability .7 and each following genome after .3 of the rejections before it
+	probabilities, sum, f := SelectionProbabilities(testFitness(16, .3, .3)), 0.0, float64(float32(.3))
+	for i, p := range probabilities {
+		if expected := (1 - f) * math.Pow(f, float64(i)) / (1 - math.Pow(f, 16)); math.Abs(p-expected) > 1e-12 {
+			t.Errorf("genome %d has a selection probability of %g, expected %g", i, p, expected)
+		}
+		sum += p
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("selection probabilities sum to %f, expected 1", sum)
+	}
+	// every pass rejecting every genome leaves the genome with the lowest fitness
+	if probabilities := SelectionProbabilities([]float32{1.5, 1, 1.2}); probabilities[1] != 1 {
+		t.Errorf("selection probabilities of a population that is never accepted are %v, expected the lowest fitness", probabilities)
+	}
+}
+
+func TestSelectionPressureDominant(t *testing.T) {
+	fitness := testFitness(64, .01, .99)
+	pressure := SelectionPressure(fitness)
+	if pressure < 10 {
+		t.Errorf("population with a dominant genome has a pressure of %f, expected a high pressure", pressure)
+	}
+	// the genomes are visited in order, so the dominant genome is selected less often when it is visited last
+	fitness[0], fitness[len(fitness)-1] = fitness[len(fitness)-1], fitness[0]
+	if last := SelectionPressure(fitness); last >= pressure {
+		t.Errorf("dominant genome visited last has a pressure of %f, expected less than %f", last, pressure)
+	}
+	// the best genome wins every tournament it is drawn in
+	if pressure := TournamentPressure(fitness, 4); pressure < 3.8 || pressure > 4 {
+		t.Errorf("population with a dominant genome has a tournament pressure of %f, expected nearly 4", pressure)
+	}
+}
+
+func TestSelectionPressureRejected(t *testing.T) {
+	// no genome is ever accepted, so the best genome is always selected
+	if pressure := SelectionPressure(testFitness(8, 1, 1.5)); pressure != 8 {
+		t.Errorf("population that is never accepted has a pressure of %f, expected 8", pressure)
+	}
+}
+
+func TestProportionalSelection(t *testing.T) {
+	rnd := Rand(LFSRInit)
+	// no genome is ever accepted, so the genome with the lowest fitness is returned
+	genomes := []Genome{{Fitness: 1.5}, {Fitness: 1}, {Fitness: 1.2}}
+	if i := ProportionalSelection(genomes, &rnd); i != 1 {
+		t.Fatalf("selected genome %d of a population that is never accepted, expected 1", i)
+	}
+	genomes = []Genome{{Fitness: 1}, {Fitness: 0}}
+	if i := ProportionalSelection(genomes, &rnd); i != 1 {
+		t.Fatalf("selected genome %d, expected the only accepted genome 1", i)
+	}
+}
+
+func TestTournamentSelection(t *testing.T) {
+	genomes := make([]Genome, 8)
+	for i := range genomes {
+		genomes[i].Fitness = float32(i)
+	}
+	rnd, expected := Rand(LFSRInit), Rand(LFSRInit)
+	for n := 0; n < 16; n++ {
+		best := expected.IntN(8)
+		for i := 1; i < 3; i++ {
+			if j := expected.IntN(8); j < best {
+				best = j
+			}
+		}
+		if i := TournamentSelection(3)(genomes, &rnd); i != best {
+			t.Fatalf("tournament %d selected genome %d, expected %d", n, i, best)
+		}
+	}
+	if i := TournamentSelection(1000)(genomes, &rnd); i != 0 {
+		t.Fatalf("large tournament selected genome %d, expected the best 0", i)
+	}
+}
+
+func TestNewSelection(t *testing.T) {
+	if selection, err := NewSelection("proportional", 3); selection != nil || err != nil {
+		t.Errorf("proportional selection is %v with error %v, expected nil", selection, err)
+	}
+	if selection, err := NewSelection("tournament", 3); selection == nil || err != nil {
+		t.Errorf("tournament selection error %v", err)
+	}
+	if _, err := NewSelection("roulette", 3); err == nil {
+		t.Error("unknown selection error is nil")
+	}
+}
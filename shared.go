@@ -8,17 +8,15 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
-	"sort"
-
-	"github.com/pointlander/datum/iris"
 )
 
 // SharedLayer is a neural network layer with shared weights
 type SharedLayer struct {
-	Rows    int
-	Columns int
-	Weights []float32
-	Rand    Rand
+	Rows       int
+	Columns    int
+	Weights    []float32
+	Rand       Rand
+	Activation string
 }
 
 // SharedNetwork is a neural network with shared weights
@@ -28,21 +26,23 @@ type SharedNetwork []SharedLayer
 func (n SharedNetwork) Inference(inputs, outputs []float32) {
 	last := len(n) - 1
 	for i, layer := range n {
-		rnd := layer.Rand
+		activation := LookupActivation(layer.Activation)
+		rnd := NewSource(layer.Rand)
 		columns := len(outputs)
 		if i < len(n)-1 {
 			columns = n[i+1].Columns
 		}
-		mask, values :=
+		mask, values, row :=
 			uint32((1<<bits.TrailingZeros(uint(len(layer.Weights))))-1),
-			make([]float32, columns)
+			make([]float32, columns),
+			make([]float32, layer.Columns)
 		for j := 0; j < layer.Rows; j++ {
 			sum := layer.Weights[rnd.Uint32()&mask]
-			for k := 0; k < layer.Columns; k++ {
-				sum += inputs[k] * layer.Weights[rnd.Uint32()&mask]
+			MaterializePoolRow(rnd, row, layer.Weights, mask)
+			for k, weight := range row {
+				sum += inputs[k] * weight
 			}
-			e := float32(math.Exp(float64(sum)))
-			values[j] = e / (e + 1)
+			values[j] = activation(sum)
 		}
 		if i == last {
 			copy(outputs, values)
@@ -52,15 +52,60 @@ func (n SharedNetwork) Inference(inputs, outputs []float32) {
 	}
 }
 
+// Predict returns the index of the largest output of the network
+func (n SharedNetwork) Predict(inputs []float32) int {
+	outputs := make([]float32, n[len(n)-1].Rows)
+	n.Inference(inputs, outputs)
+	return Argmax(outputs)
+}
+
+// NumParameters counts the shared weights of the network
+func (n SharedNetwork) NumParameters() int {
+	count := 0
+	for _, layer := range n {
+		count += len(layer.Weights)
+	}
+	return count
+}
+
+// Validate checks that the network is well formed
+func (n SharedNetwork) Validate() error {
+	if len(n) == 0 {
+		return fmt.Errorf("network has no layers")
+	}
+	previous := 0
+	for i, layer := range n {
+		if err := validateColumns(i, layer.Columns, previous); err != nil {
+			return err
+		}
+		if layer.Rows <= 0 {
+			return fmt.Errorf("layer %d has %d rows, expected a positive number", i, layer.Rows)
+		}
+		// the weights are indexed with a mask, so there must be a power of two of them
+		if size := len(layer.Weights); size == 0 || size&(size-1) != 0 {
+			return fmt.Errorf("layer %d has %d shared weights, expected a power of two", i, size)
+		}
+		if err := validateRand(i, layer.Rand); err != nil {
+			return err
+		}
+		if err := validateActivation(i, layer.Activation); err != nil {
+			return err
+		}
+		previous = layer.Rows
+	}
+	return nil
+}
+
 // Copy copies a network
 func (n SharedNetwork) Copy() SharedNetwork {
 	var network SharedNetwork
 	for _, layer := range n {
 		l := SharedLayer{
-			Rows:    layer.Rows,
-			Columns: layer.Columns,
-			Weights: make([]float32, len(layer.Weights)),
-			Rand:    layer.Rand,
+			Rows:       layer.Rows,
+			Columns:    layer.Columns,
+			Weights:    make([]float32, len(layer.Weights)),
+			Rand:       layer.Rand,
+			Activation: layer.Activation,
 		}
 		copy(l.Weights, layer.Weights)
 		network = append(network, l)
@@ -68,161 +113,201 @@ func (n SharedNetwork) Copy() SharedNetwork {
 	return network
 }
 
+// EffectiveWeights materializes the weights layer i uses for an input of length inputs, a row of inputs weights per neuron
+func (n SharedNetwork) EffectiveWeights(i, inputs int) []float32 {
+	layer := n[i]
+	rnd := NewSource(layer.Rand)
+	mask, weights :=
+		uint32((1<<bits.TrailingZeros(uint(len(layer.Weights))))-1),
+		make([]float32, layer.Rows*inputs)
+	for j := 0; j < layer.Rows; j++ {
+		// skip the bias
+		rnd.Uint32()
+		MaterializePoolRow(rnd, weights[j*inputs:(j+1)*inputs], layer.Weights, mask)
+	}
+	return weights
+}
+
+// WeightStats computes the statistics of the effective weights of layer i for an input of length inputs
+func (n SharedNetwork) WeightStats(i, inputs int) WeightStats {
+	return NewWeightStats(n.EffectiveWeights(i, inputs))
+}
+
+// SquaredNorm is the sum of the squares of the shared weights of the network
+func (n SharedNetwork) SquaredNorm() float32 {
+	sum := float32(0)
+	for _, layer := range n {
+		for _, weight := range layer.Weights {
+			sum += weight * weight
+		}
+	}
+	return sum
+}
+
+// SharedToReal expands the shared weights into a dense real network with the same inference
+func SharedToReal(n SharedNetwork) RealNetwork {
+	var network RealNetwork
+	for _, layer := range n {
+		rnd := NewSource(layer.Rand)
+		mask := uint32((1 << bits.TrailingZeros(uint(len(layer.Weights)))) - 1)
+		l := RealLayer{
+			Columns:    layer.Columns,
+			Weights:    make([]float32, layer.Rows*layer.Columns),
+			Biases:     make([]float32, layer.Rows),
+			Rand:       layer.Rand,
+			Activation: layer.Activation,
+			Dense:      true,
+		}
+		for j := 0; j < layer.Rows; j++ {
+			l.Biases[j] = layer.Weights[rnd.Uint32()&mask]
+			for k := 0; k < layer.Columns; k++ {
+				l.Weights[j*layer.Columns+k] = layer.Weights[rnd.Uint32()&mask]
+			}
+		}
+		network = append(network, l)
+	}
+	return network
+}
+
+// SharedModel is the shared network model
+type SharedModel struct{}
+
+// Train trains the model
+func (SharedModel) Train(seed int) float64 {
+	return SharedNetworkModel(seed)
+}
+
 // SharedNetworkModel is the real network with shared weights
 func SharedNetworkModel(seed int) float64 {
+	_, quality := SharedNetworkModelBest(seed)
+	return quality
+}
+
+// SharedNetworkModelBest is the real network with shared weights that returns the best network
+func SharedNetworkModelBest(seed int) (SharedNetwork, float64) {
+	network, _, quality := sharedNetworkModel(seed)
+	return network, quality
+}
+
+// SharedNetworkModelHistory is the real network with shared weights that returns the best fitness of each generation
+func SharedNetworkModelHistory(seed int) ([]float32, float64) {
+	_, history, quality := sharedNetworkModel(seed)
+	return history, quality
+}
+
+// sharedNetworkModel trains the real network with shared weights returning the best network, the best fitness of each generation, and the quality
+func sharedNetworkModel(seed int) (SharedNetwork, []float32, float64) {
 	rnd := Rand(LFSRInit + seed)
-	type Genome struct {
-		Network SharedNetwork
-		Fitness float32
-	}
+	train, test := LoadData()
 	var genomes []Genome
 	addNetwork := func(i int) {
+		sizes := Architecture(train.Features, train.Classes)
 		var network SharedNetwork
-		layer := SharedLayer{
-			Rows:    4,
-			Columns: 4,
-			Weights: make([]float32, 4),
-			Rand:    Rand(LFSRInit + i + seed + NumGenomes),
-		}
-		factor := float32(math.Sqrt(2 / float64(4)))
-		for i := range layer.Weights {
-			layer.Weights[i] = (2*rnd.Float32() - 1) * factor
-		}
-		network = append(network, layer)
-
-		layer = SharedLayer{
-			Rows:    3,
-			Columns: 4,
-			Weights: make([]float32, 4),
-			Rand:    Rand(LFSRInit + i + seed + 2*NumGenomes),
-		}
-		factor = float32(math.Sqrt(2 / float64(3)))
-		for i := range layer.Weights {
-			layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+		for l := 1; l < len(sizes); l++ {
+			layer := SharedLayer{
+				Rows:       sizes[l],
+				Columns:    sizes[l-1],
+				Weights:    make([]float32, *Pool),
+				Rand:       LayerSeed(seed, i, l),
+				Activation: *ActivationName,
+			}
+			factor := float32(math.Sqrt(2 / float64(sizes[l])))
+			for i := range layer.Weights {
+				layer.Weights[i] = (2*rnd.Float32() - 1) * factor
+			}
+			network = append(network, layer)
 		}
-		network = append(network, layer)
 
 		genomes = append(genomes, Genome{
 			Network: network,
 		})
 	}
-	for i := 0; i < NumGenomes; i++ {
+	for i := 0; i < *Genomes; i++ {
 		addNetwork(i)
 	}
+	genomes = Resume(genomes)
 
-	datum, err := iris.Load()
-	if err != nil {
-		panic(err)
-	}
-
-	inputs, outputs := make([]float32, 4), make([]float32, 3)
-	get := func() int {
-		for {
-			for i, genome := range genomes {
-				if rnd.Float32() > genome.Fitness {
-					return i
-				}
+	batchRnd := Rand(LFSRInit + seed + 3*(*Genomes))
+	batch := Batch(train.Samples, *BatchSize, &batchRnd)
+	current := 0
+	genomes, history := EvolveHistory(Evolution{
+		Population:      *Genomes,
+		Elite:           *Elite,
+		Generations:     *Generations,
+		Crossovers:      Offspring(*NumCrossovers),
+		Mutations:       Offspring(*NumMutations),
+		MaxEvaluations:  *MaxEvaluations,
+		Context:         Context,
+		Rand:            &rnd,
+		Selection:       ParentSelection,
+		Samples:         len(batch),
+		Timing:          *Timing,
+		Verbose:         *Verbose,
+		CheckpointEvery: *CheckpointEvery,
+		ShareSigma:      float32(*ShareSigma),
+		Checkpoint:      CheckpointPopulation,
+		Generation: func(generation int, genomes []Genome) {
+			current = generation
+			if *BatchSize > 0 && generation > 0 {
+				batch = Batch(train.Samples, *BatchSize, &batchRnd)
 			}
-		}
-	}
-	i := 0
-	for {
-		for j, genome := range genomes {
-			sum := float32(0)
-			for _, flower := range datum.Fisher {
-				for k, value := range flower.Measures {
-					inputs[k] = float32(value)
-				}
-				genome.Network.Inference(inputs, outputs)
-				expected := make([]float32, 3)
-				expected[iris.Labels[flower.Label]] = 1
-				loss := float32(0)
-				for l, output := range outputs {
-					diff := expected[l] - output
-					loss += diff * diff
-				}
-				loss = float32(math.Sqrt(float64(loss)))
-				sum += loss
-			}
-			sum /= float32(len(datum.Fisher)) * float32(math.Sqrt(3))
-			genomes[j].Fitness = sum
-		}
-		sort.Slice(genomes, func(i, j int) bool {
-			if math.IsNaN(float64(genomes[i].Fitness)) {
-				return false
+			if *ShuffleFlag {
+				batch = Shuffle(batch, &rnd)
 			}
-			if math.IsNaN(float64(genomes[j].Fitness)) {
-				return true
+		},
+		Fitness: func(network interface{}) float32 {
+			outputs, sum := make([]float32, train.Classes), float32(0)
+			for _, sample := range batch {
+				network.(SharedNetwork).Inference(sample.Inputs, outputs)
+				expected := make([]float32, train.Classes)
+				expected[sample.Label] = 1
+				sum += FitnessLoss.Compute(expected, outputs)
 			}
-			return genomes[i].Fitness < genomes[j].Fitness
-		})
-		genomes = genomes[:NumGenomes]
-		i++
-		if i > 127 {
-			break
-		}
-
-		for i := 0; i < 256; i++ {
-			a, b := get(), get()
-			layer, valueA, valueB :=
-				rnd.Uint32()&1, rnd.Uint32()&3, rnd.Uint32()&3
+			sum /= float32(len(batch)) * NormalizingLoss(FitnessLoss, train.Classes)
+			return Penalize(sum, network.(SharedNetwork))
+		},
+		Crossover: func(a, b interface{}) []interface{} {
+			layer := rnd.IntN(len(a.(SharedNetwork)))
 			networkA, networkB :=
-				genomes[a].Network.Copy(), genomes[b].Network.Copy()
+				a.(SharedNetwork).Copy(), b.(SharedNetwork).Copy()
 			layerA, layerB := networkA[layer], networkB[layer]
-			if layer == 1 {
-				for valueA > 2 {
-					valueA = rnd.Uint32() & 3
-				}
-				for valueB > 2 {
-					valueB = rnd.Uint32() & 3
-				}
-			}
+			valueA, valueB :=
+				rnd.IntN(len(layerA.Weights)), rnd.IntN(len(layerB.Weights))
 			layerA.Weights[valueA], layerB.Weights[valueB] =
 				layerB.Weights[valueB], layerA.Weights[valueA]
-			genomes = append(genomes, Genome{
-				Network: networkA,
-			})
-			genomes = append(genomes, Genome{
-				Network: networkB,
-			})
-		}
+			return []interface{}{networkA, networkB}
+		},
+		Mutate: func(n interface{}) interface{} {
+			network := n.(SharedNetwork).Copy()
+			l := network[rnd.IntN(len(network))]
+			value := rnd.IntN(len(l.Weights))
+			l.Weights[value] += MutationScale(current) * ((2 * rnd.Float32()) - 1)
+			return network
+		},
+	}, genomes)
+	SaveFinalPopulation(genomes)
 
-		for i := 0; i < NumGenomes; i++ {
-			layer, value :=
-				rnd.Uint32()&1, rnd.Uint32()&3
-			network := genomes[i].Network.Copy()
-			l := network[layer]
-			if layer == 1 {
-				for value > 2 {
-					value = rnd.Uint32() & 3
-				}
-			}
-			l.Weights[value] += ((2 * rnd.Float32()) - 1)
-			genomes = append(genomes, Genome{
-				Network: network,
-			})
-		}
-	}
-
-	network := genomes[0].Network
-	misses, total := 0, 0
-	for _, flower := range datum.Fisher {
-		for k, value := range flower.Measures {
-			inputs[k] = float32(value)
-		}
-		network.Inference(inputs, outputs)
-		max, index := float32(0), 0
-		for j, output := range outputs {
-			if output > max {
-				max, index = output, j
-			}
-		}
-		if index != iris.Labels[flower.Label] {
+	network := genomes[0].Network.(SharedNetwork)
+	misses, total, matrix := 0, 0, NewConfusionMatrix(test.Labels)
+	for _, sample := range test.Samples {
+		index := network.Predict(sample.Inputs)
+		if index != sample.Label {
 			misses++
 		}
+		matrix.Add(sample.Label, index)
 		total++
 	}
 	quality := float64(misses) / float64(total)
-	fmt.Println(genomes[0].Fitness, quality)
-	return quality
+	Println(genomes[0].Fitness, quality)
+	PrintEnsembleQuality(genomes, test.Samples)
+	if *Params {
+		Println("parameters", network.NumParameters())
+	}
+	if *ConfusionFlag {
+		fmt.Fprint(Output, matrix)
+	}
+	if *MetricsFlag {
+		fmt.Fprint(Output, matrix.Metrics())
+	}
+	return network, history, quality
 }
@@ -0,0 +1,48 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSharedToReal(t *testing.T) {
+	shared := goldenShared()
+	network := SharedToReal(shared)
+	if err := network.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	data := SyntheticDataset(4, 3, 30, 1)
+	outputs, expected := make([]float32, 3), make([]float32, 3)
+	for _, sample := range data.Samples {
+		network.Inference(sample.Inputs, outputs)
+		shared.Inference(sample.Inputs, expected)
+		for j := range outputs {
+			if math.Abs(float64(outputs[j]-expected[j])) > 1e-6 {
+				t.Fatalf("expanded outputs %v, expected the shared outputs %v", outputs, expected)
+			}
+		}
+	}
+}
+
+func TestSharedValidate(t *testing.T) {
+	if err := goldenShared().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, change := range map[string]func(n SharedNetwork) SharedNetwork{
+		"no layers":  func(n SharedNetwork) SharedNetwork { return nil },
+		"columns":    func(n SharedNetwork) SharedNetwork { n[1].Columns = 5; return n },
+		"rows":       func(n SharedNetwork) SharedNetwork { n[0].Rows = 0; return n },
+		"pool":       func(n SharedNetwork) SharedNetwork { n[0].Weights = n[0].Weights[:3]; return n },
+		"empty pool": func(n SharedNetwork) SharedNetwork { n[1].Weights = nil; return n },
+		"zero seed":  func(n SharedNetwork) SharedNetwork { n[1].Rand = 0; return n },
+		"activation": func(n SharedNetwork) SharedNetwork { n[0].Activation = "step"; return n },
+	} {
+		if err := change(goldenShared()).Validate(); err == nil {
+			t.Errorf("network with invalid %s is valid", name)
+		}
+	}
+}
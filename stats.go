@@ -0,0 +1,46 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Stats are summary statistics of a set of values
+type Stats struct {
+	Mean              float64
+	Median            float64
+	StandardDeviation float64
+	Min               float64
+	Max               float64
+}
+
+// Statistics computes the summary statistics of values
+func Statistics(values []float64) Stats {
+	var stats Stats
+	if len(values) == 0 {
+		return stats
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[len(sorted)-1]
+	if middle := len(sorted) / 2; len(sorted)%2 == 0 {
+		stats.Median = (sorted[middle-1] + sorted[middle]) / 2
+	} else {
+		stats.Median = sorted[middle]
+	}
+	for _, value := range sorted {
+		stats.Mean += value
+	}
+	stats.Mean /= float64(len(sorted))
+	for _, value := range sorted {
+		diff := value - stats.Mean
+		stats.StandardDeviation += diff * diff
+	}
+	stats.StandardDeviation = math.Sqrt(stats.StandardDeviation / float64(len(sorted)))
+	return stats
+}
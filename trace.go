@@ -0,0 +1,58 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TracingSource is a source of random numbers that writes every number it returns to a writer
+// Each line is the seed of the source, the method called, and the number returned
+type TracingSource struct {
+	Source
+	Seed   Rand
+	Writer io.Writer
+}
+
+// Float32 returns and traces a random float32 between 0 and 1
+func (t *TracingSource) Float32() float32 {
+	value := t.Source.Float32()
+	fmt.Fprintf(t.Writer, "%d float32 %v\n", t.Seed, value)
+	return value
+}
+
+// Uint32 returns and traces a random uint32
+func (t *TracingSource) Uint32() uint32 {
+	value := t.Source.Uint32()
+	fmt.Fprintf(t.Writer, "%d uint32 %d\n", t.Seed, value)
+	return value
+}
+
+// lockedWriter serializes the writes of concurrent fitness evaluations so that trace lines don't interleave
+type lockedWriter struct {
+	sync.Mutex
+	io.Writer
+}
+
+// Write writes p while holding the lock
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	return l.Writer.Write(p)
+}
+
+// TraceSources replaces NewSource so that every source it creates traces to writer
+func TraceSources(writer io.Writer) {
+	newSource, locked := NewSource, &lockedWriter{Writer: writer}
+	NewSource = func(seed Rand) Source {
+		return &TracingSource{
+			Source: newSource(seed),
+			Seed:   seed,
+			Writer: locked,
+		}
+	}
+}
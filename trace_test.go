@@ -0,0 +1,25 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTraceSources(t *testing.T) {
+	defer func(newSource func(seed Rand) Source) { NewSource = newSource }(NewSource)
+	var trace bytes.Buffer
+	TraceSources(&trace)
+	source, rnd := NewSource(7), Rand(7)
+	a, b := source.Float32(), source.Uint32()
+	if expected, expectedB := rnd.Float32(), rnd.Uint32(); a != expected || b != expectedB {
+		t.Fatalf("traced source returned %f and %d, expected %f and %d", a, b, expected, expectedB)
+	}
+	if expected := fmt.Sprintf("7 float32 %v\n7 uint32 %d\n", a, b); trace.String() != expected {
+		t.Fatalf("trace is %q, expected %q", trace.String(), expected)
+	}
+}
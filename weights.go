@@ -0,0 +1,70 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "math"
+
+// WeightStats are the statistics of the effective weights of a layer
+type WeightStats struct {
+	Mean     float64
+	Variance float64
+	Min      float64
+	Max      float64
+}
+
+// NewWeightStats computes the statistics of weights
+func NewWeightStats(weights []float32) WeightStats {
+	stats := WeightStats{
+		Min: math.Inf(1),
+		Max: math.Inf(-1),
+	}
+	if len(weights) == 0 {
+		return WeightStats{}
+	}
+	for _, weight := range weights {
+		w := float64(weight)
+		stats.Mean += w
+		if w < stats.Min {
+			stats.Min = w
+		}
+		if w > stats.Max {
+			stats.Max = w
+		}
+	}
+	stats.Mean /= float64(len(weights))
+	for _, weight := range weights {
+		diff := float64(weight) - stats.Mean
+		stats.Variance += diff * diff
+	}
+	stats.Variance /= float64(len(weights))
+	return stats
+}
+
+// MaterializeRow draws the random weights a neuron applies to its inputs into row, one draw per input
+// The weight at index is not drawn because the neuron uses its stored weight there, a negative index draws every weight
+// The random weights are in [-1, 1) and are scaled by the factor of the layer when applied
+func MaterializeRow(rnd Source, row []float32, index int) {
+	for k := range row {
+		if k != index {
+			row[k] = 2*rnd.Float32() - 1
+		}
+	}
+}
+
+// MaterializeComplexRow draws the random complex weights a neuron applies to its inputs into row, like MaterializeRow
+func MaterializeComplexRow(rnd Source, row []complex64, index int) {
+	for k := range row {
+		if k != index {
+			row[k] = complex(2*rnd.Float32()-1, 2*rnd.Float32()-1)
+		}
+	}
+}
+
+// MaterializePoolRow draws the weights a neuron applies to its inputs into row from a pool of shared weights
+func MaterializePoolRow(rnd Source, row, pool []float32, mask uint32) {
+	for k := range row {
+		row[k] = pool[rnd.Uint32()&mask]
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The rndnet Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testInputs are the measures of the first iris sample
+var testInputs = []float32{5.1, 3.5, 1.4, 0.2}
+
+// goldenReal is a real network with fixed weights and seeds
+func goldenReal() RealNetwork {
+	return RealNetwork{
+		{Columns: 4, Weights: []float32{.5, -.25, .75, -1}, Biases: []float32{.1, -.2, .3, -.4}, Rand: 12345},
+		{Columns: 4, Weights: []float32{-.5, .25, 1}, Biases: []float32{.2, 0, -.2}, Rand: 67890},
+	}
+}
+
+// goldenShared is a shared network with fixed weights and seeds
+func goldenShared() SharedNetwork {
+	return SharedNetwork{
+		{Rows: 4, Columns: 4, Weights: []float32{.5, -.25, .75, -1}, Rand: 12345},
+		{Rows: 3, Columns: 4, Weights: []float32{-.5, .25, 1, -.75}, Rand: 67890},
+	}
+}
+
+// goldenRandom is a random network with fixed seeds
+func goldenRandom() RandomNetwork {
+	return RandomNetwork{
+		{Rows: 4, Columns: 4, Rand: 12345, Scale: 1},
+		{Rows: 3, Columns: 4, Rand: 67890, Scale: 1},
+	}
+}
+
+// goldenComplex is a complex network with fixed weights and seeds
+func goldenComplex() ComplexNetwork {
+	return ComplexNetwork{
+		{Columns: 4, Weights: []complex64{.5 + .1i, -.25, .75 - .5i, -1i}, Biases: []complex64{.1, -.2i, .3, -.4}, Rand: 12345},
+		{Columns: 4, Weights: []complex64{-.5i, .25 + .25i, 1}, Biases: []complex64{.2, .1i, -.2}, Rand: 67890},
+	}
+}
+
+func TestGoldenInference(t *testing.T) {
+	// the outputs of the inference of the original networks, which every refactoring must preserve
+	outputs := make([]float32, 3)
+	goldenReal().Inference(testInputs, outputs)
+	if expected := []float32{0.5830335, 0.7833509, 0.33649522}; !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("real outputs %v, expected %v", outputs, expected)
+	}
+	goldenShared().Inference(testInputs, outputs)
+	if expected := []float32{0.5133463, 0.13933593, 0.73013157}; !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("shared outputs %v, expected %v", outputs, expected)
+	}
+	goldenRandom().Inference(testInputs, outputs)
+	if expected := []float32{0.44988212, 0.7576375, 0.14525852}; !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("random outputs %v, expected %v", outputs, expected)
+	}
+	inputs, complexOutputs := make([]complex64, 4), make([]complex64, 3)
+	RealEncoding.Encode(inputs, testInputs)
+	goldenComplex().Inference(inputs, complexOutputs)
+	expected := []complex64{0.53490406 + 0.072023034i, 0.42836362 + 0.018165339i, 0.48969692 - 0.096094914i}
+	if !reflect.DeepEqual(complexOutputs, expected) {
+		t.Errorf("complex outputs %v, expected %v", complexOutputs, expected)
+	}
+}
+
+func TestMaterializeRow(t *testing.T) {
+	for _, index := range []int{-1, 0, 3} {
+		rnd, expected := Rand(LFSRInit), make([]float32, 5)
+		for k := range expected {
+			if k != index {
+				expected[k] = 2*rnd.Float32() - 1
+			}
+		}
+		source, row := Rand(LFSRInit), make([]float32, 5)
+		MaterializeRow(&source, row, index)
+		if !reflect.DeepEqual(row, expected) {
+			t.Errorf("row %v with index %d, expected %v", row, index, expected)
+		}
+		if source != rnd {
+			t.Errorf("row with index %d left the source at %d, expected %d", index, source, rnd)
+		}
+	}
+}
+
+func TestMaterializeComplexRow(t *testing.T) {
+	rnd, expected := Rand(LFSRInit), make([]complex64, 4)
+	for k := range expected {
+		if k != 1 {
+			expected[k] = complex(2*rnd.Float32()-1, 2*rnd.Float32()-1)
+		}
+	}
+	source, row := Rand(LFSRInit), make([]complex64, 4)
+	MaterializeComplexRow(&source, row, 1)
+	if !reflect.DeepEqual(row, expected) || source != rnd {
+		t.Errorf("row %v, expected %v", row, expected)
+	}
+}
+
+func TestMaterializePoolRow(t *testing.T) {
+	pool, rnd, expected := []float32{.5, -.25, .75, -1}, Rand(LFSRInit), make([]float32, 6)
+	for k := range expected {
+		expected[k] = pool[rnd.Uint32()&3]
+	}
+	source, row := Rand(LFSRInit), make([]float32, 6)
+	MaterializePoolRow(&source, row, pool, 3)
+	if !reflect.DeepEqual(row, expected) || source != rnd {
+		t.Errorf("row %v, expected %v", row, expected)
+	}
+}
+
+func TestNewWeightStats(t *testing.T) {
+	stats := NewWeightStats([]float32{-1, 0, 1, 4})
+	if stats.Mean != 1 || stats.Variance != 3.5 || stats.Min != -1 || stats.Max != 4 {
+		t.Errorf("stats %+v, expected a mean of 1, variance of 3.5, min of -1, and max of 4", stats)
+	}
+	if stats := NewWeightStats(nil); stats != (WeightStats{}) {
+		t.Errorf("stats of no weights %+v, expected zero", stats)
+	}
+}
+
+func TestEffectiveWeights(t *testing.T) {
+	// the effective weights of a network with linear activations reproduce its inference
+	network := goldenReal()
+	for i := range network {
+		network[i].Activation = "relu"
+	}
+	inputs := testInputs
+	for i, layer := range network {
+		weights, values := network.EffectiveWeights(i, layer.Columns), make([]float32, len(layer.Biases))
+		for j := range values {
+			sum := layer.Biases[j]
+			for k, input := range inputs {
+				sum += input * weights[j*layer.Columns+k]
+			}
+			values[j] = ReLU(sum)
+		}
+		inputs = values
+	}
+	outputs := make([]float32, 3)
+	network.Inference(testInputs, outputs)
+	for j := range outputs {
+		if math.Abs(float64(outputs[j]-inputs[j])) > 1e-5 {
+			t.Fatalf("outputs %v, expected %v from the effective weights", outputs, inputs)
+		}
+	}
+	for name, stats := range map[string]WeightStats{
+		"real":    network.WeightStats(0, 4),
+		"shared":  goldenShared().WeightStats(0, 4),
+		"random":  goldenRandom().WeightStats(0, 4),
+		"complex": goldenComplex().WeightStats(0, 4),
+	} {
+		if stats.Variance == 0 || stats.Min >= stats.Max {
+			t.Errorf("%s effective weights have stats %+v, expected distinct weights", name, stats)
+		}
+	}
+}